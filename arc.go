@@ -0,0 +1,100 @@
+package cache
+
+// arc is the Adaptive Replacement Cache policy. t1 holds the keys seen once
+// recently and t2 holds the keys seen at least twice. b1 and b2 are the ghost
+// lists of the keys recently evicted from t1 and t2. A hit in a ghost list
+// adapts the target size p of t1.
+type arc[K comparable] struct {
+	cap    int
+	p      int
+	t1, t2 *keyList[K]
+	b1, b2 *keyList[K]
+	pool   keyPool[K]
+}
+
+// newARC creates an empty arc policy for the given capacity.
+func newARC[K comparable](cap int) *arc[K] {
+	a := &arc[K]{cap: cap}
+	a.t1 = newKeyList(&a.pool)
+	a.t2 = newKeyList(&a.pool)
+	a.b1 = newKeyList(&a.pool)
+	a.b2 = newKeyList(&a.pool)
+	return a
+}
+
+// add puts the key into t1, or into t2 if it is in a ghost list. A ghost hit
+// grows the target size of the list it was evicted from.
+func (a *arc[K]) add(key K) {
+	switch {
+	case a.b1.contains(key):
+		a.p = minInt(a.cap, a.p+maxInt(a.b2.len/a.b1.len, 1))
+		a.b1.remove(key)
+		a.t2.pushFront(key)
+	case a.b2.contains(key):
+		a.p = maxInt(0, a.p-maxInt(a.b1.len/a.b2.len, 1))
+		a.b2.remove(key)
+		a.t2.pushFront(key)
+	default:
+		a.t1.pushFront(key)
+	}
+}
+
+// access moves the key to the front of t2.
+func (a *arc[K]) access(key K) {
+	if a.t1.remove(key) {
+		a.t2.pushFront(key)
+	} else if a.t2.contains(key) {
+		a.t2.moveToFront(key)
+	}
+}
+
+// remove forgets the key. If it is evicted, it is remembered in the ghost
+// list of the list it was in.
+func (a *arc[K]) remove(key K, reason EvictReason) {
+	switch {
+	case a.t1.remove(key):
+		if reason.evicted() {
+			a.b1.pushFront(key)
+		}
+	case a.t2.remove(key):
+		if reason.evicted() {
+			a.b2.pushFront(key)
+		}
+	}
+	a.trimGhosts()
+}
+
+// victim returns the back of t1 if t1 exceeds its target size, otherwise the
+// back of t2.
+func (a *arc[K]) victim() K {
+	if a.t1.len > 0 && (a.t1.len > a.p || a.t2.len == 0) {
+		return a.t1.back()
+	}
+	return a.t2.back()
+}
+
+// resize changes the capacity and shrinks the ghost lists if needed.
+func (a *arc[K]) resize(cap int) {
+	a.cap = cap
+	a.p = minInt(a.p, cap)
+	a.trimGhosts()
+}
+
+// clear forgets all keys.
+func (a *arc[K]) clear() {
+	a.p = 0
+	a.t1.clear()
+	a.t2.clear()
+	a.b1.clear()
+	a.b2.clear()
+}
+
+// trimGhosts keeps each ghost list within the capacity.
+func (a *arc[K]) trimGhosts() {
+	for a.b1.len > a.cap {
+		a.b1.popBack()
+	}
+	for a.b2.len > a.cap {
+		a.b2.popBack()
+	}
+}
@@ -0,0 +1,813 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// bytesSegments is the maximum number of the segments of a BytesCache.
+	bytesSegments = 16
+
+	// minSegmentSize is the minimum size of a segment in bytes. Smaller caches
+	// have fewer segments, so a single value can use a larger part of them.
+	minSegmentSize = 64 << 10
+
+	// bytesHeaderSize is the size of the header stored before every key. The
+	// header holds the expiration, the hash of the key, the lengths of the key
+	// and the value, and the flags.
+	bytesHeaderSize = 25
+
+	// flagsOffset is the offset of the flags in the header.
+	flagsOffset = 24
+
+	// flagAccessed marks the data that is read by Get since it is written or
+	// moved. Such data gets a second chance when it reaches the head of the
+	// segment.
+	flagAccessed = 1
+)
+
+// BytesCache is a cache of byte slices with string keys for holding millions
+// of small values. The keys and the values are copied into large byte buffers
+// allocated when the cache is created, and the data is found through an index
+// of the offsets in the buffers. Neither the buffers nor the index contain
+// pointers, so the garbage collector does not scan the cached data, and its
+// mark time does not grow with the length of the cache.
+//
+// The buffers are split into segments, each with its own lock. A segment is a
+// ring buffer: the data is appended to its tail, and the oldest data is evicted
+// from its head when there is no room for new data. The data read by Get since
+// it is written is moved to the tail instead of being evicted once, which
+// approximates LRU eviction. Replaced and removed data keeps its space until
+// it reaches the head.
+//
+// The capacity is the total size of the buffers in bytes. Every data takes 25
+// bytes in addition to its key and value, and the data larger than a segment
+// cannot be added. The values are copied in and out, so the slices passed to
+// and returned by the methods are not retained.
+//
+// BytesCache has the basic methods of Cache for string keys and byte slice
+// values: Add, AddOrReplace, Get, Peek, Contains, TTL, Remove, RemoveOldest,
+// Replace, UpdateVal, UpdateExpirationDate, Keys, Len, Cap, Resize, Clear,
+// ClearExpiredData, Stats, ResetStats and Close. The expiration durations have
+// the same meaning. The loaders, the atomic updates, the eviction callbacks,
+// the costs, the snapshots, the write-ahead log and the iteration methods are
+// not supported. It is safe for concurrent use.
+type BytesCache struct {
+	// segments hold the data. A key is stored in the segment chosen by its
+	// hash.
+	segments []bytesSegment
+
+	// seed is the seed of the key hashes.
+	seed maphash.Seed
+
+	// capacity is the total size of the segments.
+	capacity atomic.Int64
+
+	// clock tells the current time.
+	clock Clock
+
+	// defaultTTL is the expiration duration of the data added with zero
+	// duration.
+	defaultTTL time.Duration
+
+	// ttlJitter is the fraction that the TTLs are randomly changed by.
+	ttlJitter float64
+
+	// janitor clears the expired data periodically if the cleanup interval
+	// is given.
+	janitor *janitor
+
+	// stats holds the hit, miss, add, update and eviction counters.
+	stats stats
+}
+
+// bytesSegment is a ring buffer of the data with the index of their offsets.
+// Every data is stored as a header followed by the key and the value, and it
+// may wrap around the end of the buffer.
+type bytesSegment struct {
+	mu sync.RWMutex
+
+	// buf is the ring buffer.
+	buf []byte
+
+	// head is the offset of the oldest data.
+	head int
+
+	// used is the number of bytes from the head to the tail, including the
+	// replaced and removed data that is not evicted yet.
+	used int
+
+	// index maps the hashes of the keys to the offsets of the live data. Two
+	// keys with the same hash replace each other.
+	index map[uint64]uint32
+
+	// scratch is the buffer used to move the data to the tail.
+	scratch []byte
+}
+
+// bytesHeader is the decoded header of a data.
+type bytesHeader struct {
+	// expiration is the expiration time in Unix nanoseconds. Zero means
+	// that the data never expires.
+	expiration int64
+
+	hash   uint64
+	keyLen uint32
+	valLen uint32
+	flags  byte
+}
+
+// NewBytesCache creates a BytesCache of the given size in bytes. The size is
+// split across up to 16 segments of at least 64 KiB, and it needs to be more
+// than zero. WithCleanupInterval, WithDefaultTTL, WithTTLJitter and WithClock
+// are supported; the other options return ErrInvalidConfig.
+func NewBytesCache(size int, opts ...Option) (*BytesCache, error) {
+	cfg, err := newConfig(append(opts[:len(opts):len(opts)], WithCapacity(size)))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Policy != PolicyLRU || cfg.MaxCost != 0 || cfg.NegativeTTL != 0 || cfg.WALPath != "" ||
+		cfg.RefreshAhead != 0 || cfg.StaleWindow != 0 || cfg.SlidingExpiration ||
+		cfg.OnEvict != nil || cfg.Weigher != nil || cfg.Loader != nil {
+		return nil, fmt.Errorf("%w: option is not supported by BytesCache", ErrInvalidConfig)
+	}
+	n := maxInt(1, minInt(bytesSegments, size/minSegmentSize))
+	if uint64(shardCap(size, n, 0)) > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: size %d is too large", ErrInvalidConfig, size)
+	}
+
+	c := &BytesCache{
+		segments:   make([]bytesSegment, n),
+		seed:       maphash.MakeSeed(),
+		clock:      cfg.Clock,
+		defaultTTL: cfg.DefaultTTL,
+		ttlJitter:  cfg.TTLJitter,
+	}
+	c.capacity.Store(int64(size))
+	for i := range c.segments {
+		c.segments[i].buf = make([]byte, shardCap(size, n, i))
+		c.segments[i].index = make(map[uint64]uint32)
+	}
+	if cfg.CleanupInterval > 0 {
+		c.janitor = newJanitor(cfg.CleanupInterval, c.ClearExpiredData)
+	}
+	return c, nil
+}
+
+// Add adds a copy of val to the cache with the given key. If the key exists,
+// it returns ErrKeyExists. If the data does not fit in a segment, it returns
+// ErrEntryTooLarge. The data expires after exp; zero exp means the default
+// TTL of the cache.
+func (c *BytesCache) Add(key string, val []byte, exp time.Duration) error {
+	s, h := c.segment(key)
+	expiration := c.expiration(exp)
+	now := c.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, _, found := c.lookup(s, h, key, now); found {
+		return keyError(key, ErrKeyExists)
+	}
+	if err := c.set(s, h, key, val, expiration, now); err != nil {
+		return err
+	}
+	c.stats.adds.Add(1)
+	return nil
+}
+
+// AddOrReplace adds a copy of val to the cache with the given key, or replaces
+// the value of the existing data. The data expires after exp; KeepTTL keeps
+// the expiration of the existing data. The replaced data is moved to the tail
+// of its segment.
+func (c *BytesCache) AddOrReplace(key string, val []byte, exp time.Duration) error {
+	s, h := c.segment(key)
+	expiration := c.expiration(exp)
+	now := c.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, hdr, found := c.lookup(s, h, key, now)
+	if found && exp == KeepTTL {
+		expiration = hdr.expiration
+	}
+	if err := c.set(s, h, key, val, expiration, now); err != nil {
+		return err
+	}
+	if found {
+		c.stats.updates.Add(1)
+		c.stats.evictions[ReasonReplaced].Add(1)
+	} else {
+		c.stats.adds.Add(1)
+	}
+	return nil
+}
+
+// Get returns a copy of the value of the given key. If there is no such data
+// or the data is expired, it returns nil and false. The expired data is
+// removed from the cache. The data read by Get is kept when it would be
+// evicted for the first time.
+func (c *BytesCache) Get(key string) ([]byte, bool) {
+	s, h := c.segment(key)
+	now := c.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	off, hdr, found := c.lookup(s, h, key, now)
+	c.stats.hit(found)
+	if !found {
+		return nil, false
+	}
+	s.buf[s.wrap(off+flagsOffset)] |= flagAccessed
+	return s.value(off, hdr), true
+}
+
+// Peek returns a copy of the value of the given key like Get, but it does not
+// mark the data as read and it does not remove the expired data, so it can
+// run concurrently with other readers.
+func (c *BytesCache) Peek(key string) ([]byte, bool) {
+	s, h := c.segment(key)
+	now := c.now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	off, hdr, found := s.find(h, key)
+	found = found && !hdr.expired(now)
+	c.stats.hit(found)
+	if !found {
+		return nil, false
+	}
+	return s.value(off, hdr), true
+}
+
+// Contains reports whether the given key exists and its data is not expired.
+// It does not mark the data as read, so it can run concurrently with other
+// readers.
+func (c *BytesCache) Contains(key string) bool {
+	s, h := c.segment(key)
+	now := c.now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, hdr, found := s.find(h, key)
+	return found && !hdr.expired(now)
+}
+
+// TTL returns the remaining lifetime of the given key like TypedCache.TTL. It
+// returns NoExpiration if the data never expires, and false if there is no
+// such data or the data is expired.
+func (c *BytesCache) TTL(key string) (time.Duration, bool) {
+	s, h := c.segment(key)
+	now := c.now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, hdr, found := s.find(h, key)
+	if !found || hdr.expired(now) {
+		return 0, false
+	}
+	if hdr.expiration == 0 {
+		return NoExpiration, true
+	}
+	return time.Duration(hdr.expiration - now), true
+}
+
+// Remove deletes the data of the given key. If the cache is empty, it returns
+// ErrEmptyCache. The space of the data is reused when it reaches the head of
+// its segment.
+func (c *BytesCache) Remove(key string) error {
+	if c.Len() == 0 {
+		return ErrEmptyCache
+	}
+	s, h := c.segment(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, _, found := s.find(h, key); found {
+		delete(s.index, h)
+		c.stats.evictions[ReasonRemoved].Add(1)
+	}
+	return nil
+}
+
+// RemoveOldest removes the data at the head of the segment that uses the most
+// bytes, which is the oldest data of that segment and the next one to be
+// evicted from it. It returns the removed key and a copy of its value, and
+// false if the cache is empty. The expired data found at the heads is removed
+// on the way.
+func (c *BytesCache) RemoveOldest() (string, []byte, bool) {
+	now := c.now()
+	for {
+		s := c.fullest(now)
+		if s == nil {
+			return "", nil, false
+		}
+		s.mu.Lock()
+		key, val, ok := c.removeHead(s, now)
+		s.mu.Unlock()
+		if ok {
+			return key, val, true
+		}
+	}
+}
+
+// Replace replaces the value of the given key with a copy of val and keeps its
+// expiration. If there is no such data or the data is expired, it returns
+// ErrKeyNotFound. The replaced data is moved to the tail of its segment.
+func (c *BytesCache) Replace(key string, val []byte) error {
+	_, err := c.UpdateVal(key, val)
+	return err
+}
+
+// UpdateVal replaces the value of the given key like Replace and returns the
+// updated item. The value of the item is val itself, which is not retained by
+// the cache. If there is no such data or the data is expired, it returns
+// ErrKeyNotFound.
+func (c *BytesCache) UpdateVal(key string, val []byte) (TypedItem[string, []byte], error) {
+	s, h := c.segment(key)
+	now := c.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, hdr, found := c.lookup(s, h, key, now)
+	if !found {
+		return TypedItem[string, []byte]{}, keyError(key, ErrKeyNotFound)
+	}
+	if err := c.set(s, h, key, val, hdr.expiration, now); err != nil {
+		return TypedItem[string, []byte]{}, err
+	}
+	c.stats.updates.Add(1)
+	c.stats.evictions[ReasonReplaced].Add(1)
+	return TypedItem[string, []byte]{Key: key, Val: val, Expiration: hdr.expirationTime()}, nil
+}
+
+// UpdateExpirationDate changes the expiration of the given key so that it
+// expires after exp from now. Zero exp means the default TTL of the cache,
+// NoExpiration means that the data never expires, and KeepTTL leaves the
+// expiration unchanged. The data is marked as read like Get does. It returns
+// the updated item with a copy of the value. If there is no such data or the
+// data is expired, it returns ErrKeyNotFound.
+func (c *BytesCache) UpdateExpirationDate(key string, exp time.Duration) (TypedItem[string, []byte], error) {
+	s, h := c.segment(key)
+	expiration := c.expiration(exp)
+	now := c.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	off, hdr, found := c.lookup(s, h, key, now)
+	if !found {
+		return TypedItem[string, []byte]{}, keyError(key, ErrKeyNotFound)
+	}
+	if exp != KeepTTL {
+		hdr.expiration = expiration
+	}
+	hdr.flags |= flagAccessed
+	var p [bytesHeaderSize]byte
+	hdr.put(p[:])
+	s.write(off, p[:])
+	c.stats.updates.Add(1)
+	return TypedItem[string, []byte]{Key: key, Val: s.value(off, hdr), Expiration: hdr.expirationTime()}, nil
+}
+
+// Keys returns the keys of the data which is not expired. It does not mark
+// the data as read. The keys are in no particular order.
+func (c *BytesCache) Keys() []string {
+	now := c.now()
+	keys := make([]string, 0, c.Len())
+	for i := range c.segments {
+		s := &c.segments[i]
+		s.mu.RLock()
+		for _, off := range s.index {
+			if hdr := s.header(int(off)); !hdr.expired(now) {
+				keys = append(keys, s.key(int(off), hdr))
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return keys
+}
+
+// Len returns the number of the data in the cache. The expired data is
+// counted until it is cleared.
+func (c *BytesCache) Len() int {
+	var n int
+	for i := range c.segments {
+		s := &c.segments[i]
+		s.mu.RLock()
+		n += len(s.index)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Cap returns the capacity of the cache in bytes.
+func (c *BytesCache) Cap() int {
+	return int(c.capacity.Load())
+}
+
+// Resize changes the capacity of the cache to the given size in bytes. The
+// segments are reallocated with the new size split across them, so Resize
+// takes time proportional to the size of the cache. The segment count does
+// not change, a negative size is taken as zero, and a size larger than the
+// limit of NewBytesCache is reduced to it. If the data does not fit in the new
+// segments, the oldest data is removed. It returns the number of the removed
+// data, not counting the expired data that is dropped on the way.
+func (c *BytesCache) Resize(size int) int {
+	size = maxInt(size, 0)
+	if limit := uint64(len(c.segments)) * math.MaxUint32; uint64(size) > limit {
+		size = int(limit)
+	}
+	now := c.now()
+	var removed int
+	for i := range c.segments {
+		s := &c.segments[i]
+		s.mu.Lock()
+		removed += c.resizeSegment(s, shardCap(size, len(c.segments), i), now)
+		s.mu.Unlock()
+	}
+	c.capacity.Store(int64(size))
+	return removed
+}
+
+// Clear deletes all data from the cache.
+func (c *BytesCache) Clear() {
+	for i := range c.segments {
+		s := &c.segments[i]
+		s.mu.Lock()
+		c.stats.evictions[ReasonCleared].Add(uint64(len(s.index)))
+		s.index = make(map[uint64]uint32)
+		s.head, s.used = 0, 0
+		s.mu.Unlock()
+	}
+}
+
+// ClearExpiredData deletes all expired data from the cache. It scans the
+// index of every segment.
+func (c *BytesCache) ClearExpiredData() {
+	now := c.now()
+	for i := range c.segments {
+		s := &c.segments[i]
+		s.mu.Lock()
+		for h, off := range s.index {
+			if s.header(int(off)).expired(now) {
+				delete(s.index, h)
+				c.stats.evictions[ReasonExpired].Add(1)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns the current statistics of the cache. Cost and MaxCost are
+// always zero.
+func (c *BytesCache) Stats() Stats {
+	s := c.stats.snapshot()
+	s.Len, s.Cap = c.Len(), c.Cap()
+	return s
+}
+
+// ResetStats sets all counters of the statistics to zero.
+func (c *BytesCache) ResetStats() {
+	c.stats.reset()
+}
+
+// Close stops the background cleanup goroutine started by WithCleanupInterval
+// and waits for it to return. The cache is still usable after Close. It is
+// safe to call Close multiple times.
+func (c *BytesCache) Close() {
+	if c.janitor != nil {
+		c.janitor.close()
+	}
+}
+
+// segment returns the segment of the key and the hash of the key.
+func (c *BytesCache) segment(key string) (*bytesSegment, uint64) {
+	h := maphash.String(c.seed, key)
+	return &c.segments[h%uint64(len(c.segments))], h
+}
+
+// now returns the current time in Unix nanoseconds.
+func (c *BytesCache) now() int64 {
+	return c.clock.Now().UnixNano()
+}
+
+// expiration returns the expiration time in Unix nanoseconds of the data added
+// now with the expiration duration exp, or zero if the data never expires.
+func (c *BytesCache) expiration(exp time.Duration) int64 {
+	switch exp {
+	case NoExpiration:
+		return 0
+	case 0, KeepTTL:
+		exp = c.defaultTTL
+	}
+	if exp == 0 {
+		return 0
+	}
+	return c.clock.Now().Add(jitter(exp, c.ttlJitter)).UnixNano()
+}
+
+// lookup finds the data of the key in s like find, and removes it if it is
+// expired. The segment lock must be held for writing.
+func (c *BytesCache) lookup(s *bytesSegment, h uint64, key string, now int64) (int, bytesHeader, bool) {
+	off, hdr, found := s.find(h, key)
+	if found && hdr.expired(now) {
+		delete(s.index, h)
+		c.stats.evictions[ReasonExpired].Add(1)
+		return 0, bytesHeader{}, false
+	}
+	return off, hdr, found
+}
+
+// set appends the data to the tail of s and points the index to it. The
+// previous data of the key, if any, becomes dead. The segment lock must be
+// held for writing.
+func (c *BytesCache) set(s *bytesSegment, h uint64, key string, val []byte, expiration, now int64) error {
+	size := bytesHeaderSize + len(key) + len(val)
+	if size > len(s.buf) {
+		return keyError(key, ErrEntryTooLarge)
+	}
+	delete(s.index, h)
+	c.reserve(s, size, now)
+
+	hdr := bytesHeader{
+		expiration: expiration,
+		hash:       h,
+		keyLen:     uint32(len(key)),
+		valLen:     uint32(len(val)),
+	}
+	var p [bytesHeaderSize]byte
+	hdr.put(p[:])
+	off := s.tail()
+	end := s.write(off, p[:])
+	end = s.writeString(end, key)
+	s.write(end, val)
+	s.used += size
+	s.index[h] = uint32(off)
+	return nil
+}
+
+// reserve evicts the data from the head of s until there are size free bytes.
+// The dead data is dropped, and the live data which is read since it is
+// written is moved to the tail with its flag cleared. The segment lock must be
+// held for writing.
+func (c *BytesCache) reserve(s *bytesSegment, size int, now int64) {
+	for len(s.buf)-s.used < size {
+		off := s.head
+		hdr := s.header(off)
+		n := hdr.size()
+		s.head = s.wrap(off + n)
+		s.used -= n
+
+		if idx, ok := s.index[hdr.hash]; !ok || int(idx) != off {
+			continue
+		}
+		switch {
+		case hdr.expired(now):
+			delete(s.index, hdr.hash)
+			c.stats.evictions[ReasonExpired].Add(1)
+		case hdr.flags&flagAccessed != 0:
+			// The data is copied out first, since the tail may overlap it.
+			if cap(s.scratch) < n {
+				s.scratch = make([]byte, n)
+			}
+			p := s.scratch[:n]
+			s.read(p, off)
+			p[flagsOffset] &^= flagAccessed
+			tail := s.tail()
+			s.write(tail, p)
+			s.used += n
+			s.index[hdr.hash] = uint32(tail)
+		default:
+			delete(s.index, hdr.hash)
+			c.stats.evictions[ReasonCapacity].Add(1)
+		}
+	}
+}
+
+// fullest drops the dead and the expired data from the heads of the segments
+// and returns the segment that uses the most bytes, or nil if all segments
+// are empty.
+func (c *BytesCache) fullest(now int64) *bytesSegment {
+	var best *bytesSegment
+	var used int
+	for i := range c.segments {
+		s := &c.segments[i]
+		s.mu.Lock()
+		c.trim(s, now)
+		if s.used > used {
+			best, used = s, s.used
+		}
+		s.mu.Unlock()
+	}
+	return best
+}
+
+// removeHead removes the live data at the head of s and returns its key and
+// a copy of its value. It reports false if s has no live data. The segment
+// lock must be held for writing.
+func (c *BytesCache) removeHead(s *bytesSegment, now int64) (string, []byte, bool) {
+	c.trim(s, now)
+	if s.used == 0 {
+		return "", nil, false
+	}
+	off := s.head
+	hdr := s.header(off)
+	key, val := s.key(off, hdr), s.value(off, hdr)
+	delete(s.index, hdr.hash)
+	s.head = s.wrap(off + hdr.size())
+	s.used -= hdr.size()
+	c.stats.evictions[ReasonRemoved].Add(1)
+	return key, val, true
+}
+
+// trim drops the dead and the expired data from the head of s, so that the
+// data at the head is live unless s is empty. The segment lock must be held
+// for writing.
+func (c *BytesCache) trim(s *bytesSegment, now int64) {
+	for s.used > 0 {
+		off := s.head
+		hdr := s.header(off)
+		if idx, ok := s.index[hdr.hash]; ok && int(idx) == off {
+			if !hdr.expired(now) {
+				return
+			}
+			delete(s.index, hdr.hash)
+			c.stats.evictions[ReasonExpired].Add(1)
+		}
+		s.head = s.wrap(off + hdr.size())
+		s.used -= hdr.size()
+	}
+}
+
+// resizeSegment moves the live data of s into a new buffer of the given size
+// from the oldest to the newest, after removing the oldest data that does not
+// fit. It returns the number of the removed data. The segment lock must be
+// held for writing.
+func (c *BytesCache) resizeSegment(s *bytesSegment, size int, now int64) int {
+	var (
+		offs []int
+		live int
+	)
+	for n := 0; n < s.used; {
+		off := s.wrap(s.head + n)
+		hdr := s.header(off)
+		n += hdr.size()
+		if idx, ok := s.index[hdr.hash]; !ok || int(idx) != off {
+			continue
+		}
+		if hdr.expired(now) {
+			delete(s.index, hdr.hash)
+			c.stats.evictions[ReasonExpired].Add(1)
+			continue
+		}
+		offs = append(offs, off)
+		live += hdr.size()
+	}
+
+	var removed int
+	for ; live > size; removed++ {
+		hdr := s.header(offs[removed])
+		delete(s.index, hdr.hash)
+		c.stats.evictions[ReasonResized].Add(1)
+		live -= hdr.size()
+	}
+
+	buf := make([]byte, size)
+	var end int
+	for _, off := range offs[removed:] {
+		hdr := s.header(off)
+		s.read(buf[end:end+hdr.size()], off)
+		s.index[hdr.hash] = uint32(end)
+		end += hdr.size()
+	}
+	s.buf, s.head, s.used = buf, 0, end
+	return removed
+}
+
+// find returns the offset and the header of the data of the key in s. It
+// reports false if the key does not exist, including when another key with
+// the same hash replaced it. The segment lock must be held.
+func (s *bytesSegment) find(h uint64, key string) (int, bytesHeader, bool) {
+	idx, ok := s.index[h]
+	if !ok {
+		return 0, bytesHeader{}, false
+	}
+	off := int(idx)
+	hdr := s.header(off)
+	if int(hdr.keyLen) != len(key) || !s.equal(s.wrap(off+bytesHeaderSize), key) {
+		return 0, bytesHeader{}, false
+	}
+	return off, hdr, true
+}
+
+// tail returns the offset after the newest data.
+func (s *bytesSegment) tail() int {
+	return s.wrap(s.head + s.used)
+}
+
+// wrap returns the offset in the buffer for the offset off which may be past
+// the end of the buffer.
+func (s *bytesSegment) wrap(off int) int {
+	if off >= len(s.buf) {
+		off -= len(s.buf)
+	}
+	return off
+}
+
+// span returns the n bytes of the buffer from off. The second slice is the
+// part wrapped around the end of the buffer, or nil if there is none.
+func (s *bytesSegment) span(off, n int) ([]byte, []byte) {
+	if end := off + n; end <= len(s.buf) {
+		return s.buf[off:end], nil
+	}
+	return s.buf[off:], s.buf[:off+n-len(s.buf)]
+}
+
+// read copies the bytes from off into p.
+func (s *bytesSegment) read(p []byte, off int) {
+	a, b := s.span(off, len(p))
+	copy(p, a)
+	copy(p[len(a):], b)
+}
+
+// write copies p to off and returns the offset after it.
+func (s *bytesSegment) write(off int, p []byte) int {
+	a, b := s.span(off, len(p))
+	copy(a, p)
+	copy(b, p[len(a):])
+	return s.wrap(off + len(p))
+}
+
+// writeString copies str to off and returns the offset after it.
+func (s *bytesSegment) writeString(off int, str string) int {
+	a, b := s.span(off, len(str))
+	copy(a, str)
+	copy(b, str[len(a):])
+	return s.wrap(off + len(str))
+}
+
+// equal reports whether the bytes from off are equal to key.
+func (s *bytesSegment) equal(off int, key string) bool {
+	a, b := s.span(off, len(key))
+	return string(a) == key[:len(a)] && string(b) == key[len(a):]
+}
+
+// header decodes the header of the data at off.
+func (s *bytesSegment) header(off int) bytesHeader {
+	var p [bytesHeaderSize]byte
+	s.read(p[:], off)
+	return bytesHeader{
+		expiration: int64(binary.LittleEndian.Uint64(p[0:])),
+		hash:       binary.LittleEndian.Uint64(p[8:]),
+		keyLen:     binary.LittleEndian.Uint32(p[16:]),
+		valLen:     binary.LittleEndian.Uint32(p[20:]),
+		flags:      p[flagsOffset],
+	}
+}
+
+// key returns the key of the data at off.
+func (s *bytesSegment) key(off int, hdr bytesHeader) string {
+	p := make([]byte, hdr.keyLen)
+	s.read(p, s.wrap(off+bytesHeaderSize))
+	return string(p)
+}
+
+// value returns a copy of the value of the data at off.
+func (s *bytesSegment) value(off int, hdr bytesHeader) []byte {
+	p := make([]byte, hdr.valLen)
+	s.read(p, s.wrap(off+bytesHeaderSize+int(hdr.keyLen)))
+	return p
+}
+
+// put encodes the header into p.
+func (h bytesHeader) put(p []byte) {
+	binary.LittleEndian.PutUint64(p[0:], uint64(h.expiration))
+	binary.LittleEndian.PutUint64(p[8:], h.hash)
+	binary.LittleEndian.PutUint32(p[16:], h.keyLen)
+	binary.LittleEndian.PutUint32(p[20:], h.valLen)
+	p[flagsOffset] = h.flags
+}
+
+// size returns the number of bytes taken by the data.
+func (h bytesHeader) size() int {
+	return bytesHeaderSize + int(h.keyLen) + int(h.valLen)
+}
+
+// expirationTime returns the expiration as a time, or the zero time if the
+// data never expires.
+func (h bytesHeader) expirationTime() time.Time {
+	if h.expiration == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, h.expiration)
+}
+
+// expired reports whether the data is expired at now.
+func (h bytesHeader) expired(now int64) bool {
+	return h.expiration != 0 && h.expiration < now
+}
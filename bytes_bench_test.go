@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// gcBenchLen is the number of values in the caches measured by BenchmarkGC.
+const gcBenchLen = 1_000_000
+
+// gcBenchValue is the size of the values in the benchmarks of BytesCache.
+const gcBenchValue = 64
+
+// newBenchBytesCache creates a BytesCache with n values which fit without
+// eviction.
+func newBenchBytesCache(b *testing.B, n int) *BytesCache {
+	b.Helper()
+	size := n * (bytesHeaderSize + len(strconv.Itoa(n)) + gcBenchValue) * 5 / 4
+	c, err := NewBytesCache(size)
+	if err != nil {
+		b.Fatal(err)
+	}
+	val := make([]byte, gcBenchValue)
+	for i := 0; i < n; i++ {
+		if err := c.Add(strconv.Itoa(i), val, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return c
+}
+
+// benchGC runs a full garbage collection in every iteration, and reports the
+// average stop-the-world pause besides the duration of the collection.
+func benchGC(b *testing.B) {
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	pause := time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+	b.ReportMetric(float64(pause.Nanoseconds())/float64(after.NumGC-before.NumGC), "pause-ns/gc")
+}
+
+// BenchmarkGC compares the garbage collection cost of a cache holding one
+// million small byte slices. The collector marks every item of TypedCache,
+// while the buffers of BytesCache are not scanned.
+func BenchmarkGC(b *testing.B) {
+	b.Run("TypedCache", func(b *testing.B) {
+		c, err := NewTyped[string, []byte](gcBenchLen)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < gcBenchLen; i++ {
+			_ = c.Add(strconv.Itoa(i), make([]byte, gcBenchValue), 0)
+		}
+		benchGC(b)
+		runtime.KeepAlive(c)
+	})
+	b.Run("BytesCache", func(b *testing.B) {
+		c := newBenchBytesCache(b, gcBenchLen)
+		benchGC(b)
+		runtime.KeepAlive(c)
+	})
+}
+
+func BenchmarkBytesCache_Get(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run("size="+strconv.Itoa(n), func(b *testing.B) {
+			c := newBenchBytesCache(b, n)
+			keys := make([]string, n)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Get(keys[i%n])
+			}
+		})
+	}
+}
+
+func BenchmarkBytesCache_Add(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run("size="+strconv.Itoa(n), func(b *testing.B) {
+			c := newBenchBytesCache(b, n)
+			val := make([]byte, gcBenchValue)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.Add(strconv.Itoa(n+i), val, 0)
+			}
+		})
+	}
+}
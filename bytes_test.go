@@ -0,0 +1,506 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+// createBytesCache creates a BytesCache of the given size. It is a helper
+// function to prevent code duplication.
+func createBytesCache(t *testing.T, size int, opts ...Option) *BytesCache {
+	t.Helper()
+	c, err := NewBytesCache(size, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// checkSegments walks every segment from its head to its tail and checks that
+// the data fills exactly the used bytes and that the index points to the
+// headers of the matching hashes.
+func checkSegments(t *testing.T, c *BytesCache) {
+	t.Helper()
+	for i := range c.segments {
+		s := &c.segments[i]
+		if s.used > len(s.buf) {
+			t.Fatalf("segment %d uses %d bytes of %d", i, s.used, len(s.buf))
+		}
+		offsets := make(map[int]bool)
+		for n := 0; n < s.used; {
+			off := s.wrap(s.head + n)
+			offsets[off] = true
+			n += s.header(off).size()
+			if n > s.used {
+				t.Fatalf("segment %d data overruns the tail", i)
+			}
+		}
+		for h, off := range s.index {
+			if !offsets[int(off)] {
+				t.Fatalf("segment %d index points outside the data", i)
+			}
+			if got := s.header(int(off)).hash; got != h {
+				t.Fatalf("segment %d index points to hash %x, want %x", i, got, h)
+			}
+		}
+	}
+}
+
+func TestNewBytesCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         int
+		opts         []Option
+		wantErr      error
+		wantSegments int
+	}{
+		{name: "returns error for zero size", size: 0, wantErr: ErrZeroCapacity},
+		{name: "returns error for negative size", size: -1, wantErr: ErrNegativeCapacity},
+		{name: "returns error for eviction policy", size: 1024, opts: []Option{WithPolicy(PolicyLFU)}, wantErr: ErrInvalidConfig},
+		{name: "returns error for max cost", size: 1024, opts: []Option{WithMaxCost(10)}, wantErr: ErrInvalidConfig},
+		{name: "returns error for write-ahead log", size: 1024, opts: []Option{WithWAL(filepath.Join(t.TempDir(), "cache.wal"))}, wantErr: ErrInvalidConfig},
+		{name: "returns error for sliding expiration", size: 1024, opts: []Option{WithSlidingExpiration()}, wantErr: ErrInvalidConfig},
+		{name: "creates single segment for small size", size: 1024, wantSegments: 1},
+		{name: "splits size into segments", size: 4 * minSegmentSize, wantSegments: 4},
+		{name: "limits segment count", size: 64 * minSegmentSize, opts: []Option{WithDefaultTTL(time.Hour), WithClock(SystemClock)}, wantSegments: bytesSegments},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewBytesCache(tt.size, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error, got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(c.segments) != tt.wantSegments {
+				t.Errorf("unexpected segment count, got %v, want %v", len(c.segments), tt.wantSegments)
+			}
+			var size int
+			for i := range c.segments {
+				size += len(c.segments[i].buf)
+			}
+			if size != tt.size || c.Cap() != tt.size {
+				t.Errorf("unexpected size, got %v and %v, want %v", size, c.Cap(), tt.size)
+			}
+		})
+	}
+}
+
+func TestBytesCache_Methods(t *testing.T) {
+	c := createBytesCache(t, 1024)
+	if err := c.Remove("key"); !errors.Is(err, ErrEmptyCache) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrEmptyCache)
+	}
+
+	val := []byte("value")
+	if err := c.Add("key", val, 0); err != nil {
+		t.Fatal(err)
+	}
+	val[0] = 'X'
+	if err := c.Add("key", val, 0); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrKeyExists)
+	}
+	got, found := c.Get("key")
+	if !found || string(got) != "value" {
+		t.Fatalf("Get = %q, %v, want %q, true", got, found, "value")
+	}
+	got[0] = 'X'
+	if got, _ := c.Peek("key"); string(got) != "value" {
+		t.Errorf("returned value is shared with the cache, got %q", got)
+	}
+	if !c.Contains("key") || c.Contains("nonexistent") {
+		t.Error("Contains reports wrong existence")
+	}
+	if _, found := c.Get("nonexistent"); found {
+		t.Error("Get finds nonexistent key")
+	}
+
+	if err := c.Replace("key", []byte("new value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Replace("nonexistent", val); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrKeyNotFound)
+	}
+	if err := c.AddOrReplace("other", []byte("other value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddOrReplace("other", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, found := c.Get("other"); !found || len(got) != 0 {
+		t.Errorf("Get = %q, %v, want empty value", got, found)
+	}
+	if got, _ := c.Get("key"); string(got) != "new value" {
+		t.Errorf("Get = %q, want %q", got, "new value")
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if err := c.Remove("key"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Contains("key") || c.Len() != 1 {
+		t.Errorf("removed key exists, length is %v", c.Len())
+	}
+
+	s := c.Stats()
+	if s.Hits != 4 || s.Misses != 1 || s.Adds != 2 || s.Updates != 2 || s.Len != 1 || s.Cap != 1024 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if s.Evictions[ReasonReplaced] != 2 || s.Evictions[ReasonRemoved] != 1 {
+		t.Errorf("unexpected evictions %v", s.Evictions)
+	}
+	checkSegments(t, c)
+
+	c.Clear()
+	if c.Len() != 0 || c.Contains("other") {
+		t.Error("data exists after Clear")
+	}
+	if n := c.Stats().Evictions[ReasonCleared]; n != 1 {
+		t.Errorf("unexpected cleared count %v", n)
+	}
+	c.ResetStats()
+	if s := c.Stats(); s.Hits != 0 || len(s.Evictions) != 0 {
+		t.Errorf("stats are not reset %+v", s)
+	}
+}
+
+func TestBytesCache_UpdateVal(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c := createBytesCache(t, 1024, WithClock(clock))
+	_ = c.Add("key", []byte("value"), time.Minute)
+	_ = c.Add("other", []byte("value"), 0)
+
+	item, err := c.UpdateVal("key", []byte("new value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Key != "key" || string(item.Val) != "new value" || !item.Expiration.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if got, _ := c.Get("key"); string(got) != "new value" {
+		t.Errorf("Get = %q, want %q", got, "new value")
+	}
+	if item, _ := c.UpdateVal("other", nil); !item.Expiration.IsZero() {
+		t.Errorf("data without expiration has expiration %v", item.Expiration)
+	}
+	if _, err := c.UpdateVal("nonexistent", nil); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrKeyNotFound)
+	}
+
+	// The updated data becomes the newest one.
+	if k, _, _ := c.RemoveOldest(); k != "key" {
+		t.Errorf("unexpected oldest key, got %v, want %v", k, "key")
+	}
+	if s := c.Stats(); s.Updates != 2 || s.Evictions[ReasonReplaced] != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBytesCache_RemoveOldest(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c := createBytesCache(t, 4*minSegmentSize, WithClock(clock))
+	if _, _, ok := c.RemoveOldest(); ok {
+		t.Error("data is removed from empty cache")
+	}
+
+	for i := 0; i < 100; i++ {
+		_ = c.Add(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("val%d", i)), 0)
+	}
+	_ = c.Add("expired", []byte("val"), time.Second)
+	_ = c.Remove("key0")
+	clock.Advance(2 * time.Second)
+
+	removed := make(map[string]bool)
+	for {
+		key, val, ok := c.RemoveOldest()
+		if !ok {
+			break
+		}
+		if string(val) != "val"+key[len("key"):] {
+			t.Errorf("unexpected value of %s, got %q", key, val)
+		}
+		removed[key] = true
+	}
+	if len(removed) != 99 || removed["key0"] || removed["expired"] {
+		t.Errorf("unexpected removed keys %v", removed)
+	}
+	if c.Len() != 0 {
+		t.Errorf("unexpected length, got %v, want %v", c.Len(), 0)
+	}
+	if s := c.Stats(); s.Evictions[ReasonRemoved] != 100 || s.Expirations != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	checkSegments(t, c)
+}
+
+func TestBytesCache_Resize(t *testing.T) {
+	// Every data takes 25+3+3=31 bytes.
+	c := createBytesCache(t, 310)
+	for i := 0; i < 15; i++ {
+		_ = c.Add(fmt.Sprintf("k%02d", i), []byte("val"), 0)
+	}
+	_ = c.Remove("k07")
+
+	tests := []struct {
+		name        string
+		size        int
+		wantRemoved int
+		wantKeys    []string
+	}{
+		{name: "keeps data when growing", size: 400, wantKeys: []string{"k05", "k06", "k08", "k09", "k10", "k11", "k12", "k13", "k14"}},
+		{name: "removes oldest data when shrinking", size: 100, wantRemoved: 6, wantKeys: []string{"k12", "k13", "k14"}},
+		{name: "removes all data for zero size", size: -1, wantRemoved: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Resize(tt.size); got != tt.wantRemoved {
+				t.Errorf("unexpected removed count, got %v, want %v", got, tt.wantRemoved)
+			}
+			for _, key := range tt.wantKeys {
+				if got, _ := c.Get(key); string(got) != "val" {
+					t.Errorf("Get(%s) = %q, want %q", key, got, "val")
+				}
+			}
+			if c.Len() != len(tt.wantKeys) {
+				t.Errorf("unexpected length, got %v, want %v", c.Len(), len(tt.wantKeys))
+			}
+			if want := maxInt(tt.size, 0); c.Cap() != want || len(c.segments[0].buf) != want {
+				t.Errorf("unexpected capacity, got %v, want %v", c.Cap(), want)
+			}
+			checkSegments(t, c)
+		})
+	}
+
+	if err := c.Add("key", nil, 0); !errors.Is(err, ErrEntryTooLarge) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrEntryTooLarge)
+	}
+	c.Resize(310)
+	if err := c.Add("key", nil, 0); err != nil {
+		t.Errorf("data is not added after growing: %v", err)
+	}
+	if n := c.Stats().Evictions[ReasonResized]; n != 9 {
+		t.Errorf("unexpected resized count, got %v, want %v", n, 9)
+	}
+}
+
+func TestBytesCache_EntryTooLarge(t *testing.T) {
+	c := createBytesCache(t, 100)
+	_ = c.Add("key", []byte("value"), 0)
+
+	err := c.AddOrReplace("key", make([]byte, 100), 0)
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("unexpected error, got %v, want %v", err, ErrEntryTooLarge)
+	}
+	var keyErr *KeyError
+	if !errors.As(err, &keyErr) || keyErr.Key != "key" {
+		t.Errorf("error does not carry the key, got %v", err)
+	}
+	if got, _ := c.Get("key"); string(got) != "value" {
+		t.Errorf("data is changed by the failed replace, got %q", got)
+	}
+	if err := c.Add("fits", make([]byte, 100-bytesHeaderSize-len("fits")), 0); err != nil {
+		t.Errorf("data of the segment size is not added: %v", err)
+	}
+}
+
+func TestBytesCache_Eviction(t *testing.T) {
+	// Every data takes 25+3+3=31 bytes, so the cache holds ten of them.
+	c := createBytesCache(t, 310)
+	for i := 0; i < 10; i++ {
+		_ = c.Add(fmt.Sprintf("k%02d", i), []byte("val"), 0)
+	}
+	c.Get("k00")
+
+	_ = c.Add("k10", []byte("val"), 0)
+	_ = c.Add("k11", []byte("val"), 0)
+	if !c.Contains("k00") {
+		t.Error("read data is evicted before the data that is not read")
+	}
+	for _, key := range []string{"k01", "k02"} {
+		if c.Contains(key) {
+			t.Errorf("%s is not evicted", key)
+		}
+	}
+	if c.Len() != 10 {
+		t.Errorf("unexpected length, got %v, want %v", c.Len(), 10)
+	}
+	if n := c.Stats().Evictions[ReasonCapacity]; n != 2 {
+		t.Errorf("unexpected eviction count, got %v, want %v", n, 2)
+	}
+
+	// The data gets a single second chance, so it is evicted after the
+	// others if it is not read again.
+	for i := 12; i < 21; i++ {
+		_ = c.Add(fmt.Sprintf("k%02d", i), []byte("val"), 0)
+	}
+	if c.Contains("k00") {
+		t.Error("data is kept without being read again")
+	}
+	checkSegments(t, c)
+}
+
+func TestBytesCache_Expiration(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c := createBytesCache(t, 1024, WithClock(clock), WithDefaultTTL(time.Minute))
+
+	_ = c.Add("default", []byte("v"), 0)
+	_ = c.Add("second", []byte("v"), time.Second)
+	_ = c.Add("never", []byte("v"), NoExpiration)
+	if ttl, _ := c.TTL("default"); ttl != time.Minute {
+		t.Errorf("unexpected TTL, got %v, want %v", ttl, time.Minute)
+	}
+	if ttl, _ := c.TTL("never"); ttl != NoExpiration {
+		t.Errorf("unexpected TTL, got %v, want %v", ttl, NoExpiration)
+	}
+
+	clock.Advance(2 * time.Second)
+	if c.Contains("second") {
+		t.Error("expired data exists")
+	}
+	if _, found := c.TTL("second"); found {
+		t.Error("expired data has TTL")
+	}
+	if err := c.Replace("second", []byte("v")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrKeyNotFound)
+	}
+	if c.Len() != 2 {
+		t.Errorf("unexpected length, got %v, want %v", c.Len(), 2)
+	}
+
+	item, err := c.UpdateExpirationDate("never", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Key != "never" || string(item.Val) != "v" || !item.Expiration.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if err := c.AddOrReplace("default", []byte("new"), KeepTTL); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := c.TTL("default"); ttl != time.Minute-2*time.Second {
+		t.Errorf("TTL is not kept, got %v", ttl)
+	}
+	if _, err := c.UpdateExpirationDate("default", KeepTTL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateExpirationDate("nonexistent", time.Hour); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrKeyNotFound)
+	}
+
+	clock.Advance(time.Minute)
+	c.ClearExpiredData()
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "never" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if ttl, _ := c.TTL("never"); ttl != time.Hour-time.Minute {
+		t.Errorf("unexpected TTL, got %v, want %v", ttl, time.Hour-time.Minute)
+	}
+	if n := c.Stats().Expirations; n != 2 {
+		t.Errorf("unexpected expiration count, got %v, want %v", n, 2)
+	}
+}
+
+func TestBytesCache_ExpiredDataEvictedFirst(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c := createBytesCache(t, 90, WithClock(clock))
+	_ = c.Add("k0", []byte("val"), time.Second)
+	_ = c.Add("k1", []byte("val"), 0)
+	_ = c.Add("k2", []byte("val"), 0)
+	c.Get("k0")
+
+	clock.Advance(2 * time.Second)
+	_ = c.Add("k3", []byte("val"), 0)
+	if c.Contains("k0") || !c.Contains("k1") {
+		t.Error("read data is kept after it expires")
+	}
+	if n := c.Stats().Expirations; n != 1 {
+		t.Errorf("unexpected expiration count, got %v, want %v", n, 1)
+	}
+}
+
+func TestBytesCache_RandomOperations(t *testing.T) {
+	// The small segments make the data wrap around the end of the buffers
+	// and be evicted often. The cache may lose any data, but it must never
+	// return a value that differs from the last one written.
+	c := createBytesCache(t, 1000)
+	model := make(map[string][]byte)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("key%d", rng.Intn(50))
+		val := bytes.Repeat([]byte{byte(i)}, rng.Intn(40))
+		switch rng.Intn(5) {
+		case 0:
+			if c.Add(key, val, 0) == nil {
+				model[key] = val
+			}
+		case 1:
+			if err := c.AddOrReplace(key, val, 0); err != nil {
+				t.Fatal(err)
+			}
+			model[key] = val
+		case 2:
+			if c.Replace(key, val) == nil {
+				model[key] = val
+			}
+		case 3:
+			_ = c.Remove(key)
+			delete(model, key)
+		default:
+			c.Get(key)
+		}
+		if i%100 == 0 {
+			checkSegments(t, c)
+		}
+	}
+
+	checkSegments(t, c)
+	for _, key := range c.Keys() {
+		got, found := c.Peek(key)
+		want, ok := model[key]
+		if !found || !ok || !bytes.Equal(got, want) {
+			t.Fatalf("%s = %v, %v, want %v, %v", key, got, found, want, ok)
+		}
+	}
+}
+
+func TestBytesCache_Concurrent(t *testing.T) {
+	c := createBytesCache(t, 4*minSegmentSize)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 5000; i++ {
+				key := fmt.Sprintf("key%d", rng.Intn(1000))
+				switch rng.Intn(4) {
+				case 0:
+					_ = c.AddOrReplace(key, []byte(key), time.Minute)
+				case 1:
+					_ = c.Remove(key)
+				case 2:
+					if val, found := c.Get(key); found && string(val) != key {
+						t.Errorf("%s = %q", key, val)
+					}
+				default:
+					c.Peek(key)
+					c.Len()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	checkSegments(t, c)
+}
@@ -1,348 +1,723 @@
 package cache
 
 import (
-	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Cache is the main cache type.
-type Cache struct {
-	// len is the total cached data count.
-	len int
+// Cache is the main cache type. It accepts any comparable data as key and any
+// data as value. Use TypedCache for compile-time type safety.
+type Cache = TypedCache[any, any]
 
-	// cap is the maximum capacity of the cache.
-	cap int
+// Item is the cached data type of Cache.
+type Item = TypedItem[any, any]
 
-	// mu is the mutex variable to prevent race conditions.
-	mu sync.Mutex
+// TypedCache is the cache type that stores values of type V with keys of type
+// K.
+type TypedCache[K comparable, V any] struct {
+	// len is the total cached data count. It is only changed while holding
+	// mu, but it is read atomically, so Len does not need the lock.
+	len atomic.Int64
+
+	// cap is the maximum capacity of the cache. It is only changed while
+	// holding mu, but it is read atomically, so Cap does not need the lock.
+	cap atomic.Int64
+
+	// cost is the total cost of the cached data. It is only changed while
+	// holding mu, but it is read atomically, so Cost does not need the lock.
+	cost atomic.Int64
+
+	// maxCost is the maximum total cost of the cached data. Zero means that
+	// there is no cost limit.
+	maxCost atomic.Int64
+
+	// weigher returns the cost of a data. If it is nil, every data costs one.
+	weigher func(key K, val V) int64
+
+	// mu is the mutex variable to prevent race conditions. The operations
+	// that do not change the cache hold the read lock, so they can run
+	// concurrently.
+	mu sync.RWMutex
 
 	// lst is the doubly-linked list that stores the cached data.
-	lst *list.List
+	lst entryList[K, V]
+
+	// items maps the keys to their list entries for constant time lookups.
+	items map[K]*entry[K, V]
+
+	// janitor clears the expired data periodically. It is nil if no cleanup
+	// interval is given.
+	janitor *janitor
+
+	// onEvict is called with the removed data after the lock is released.
+	onEvict func(key K, val V, reason EvictReason)
+
+	// evicted holds the removed data until onEvict is called.
+	evicted []evicted[K, V]
+
+	// stats holds the hit, miss, add, update and eviction counters.
+	stats stats
+
+	// policy chooses the data to evict. It is nil for PolicyLRU, since lst
+	// is already in LRU order.
+	policy policy[K]
+
+	// loader deduplicates the concurrent GetOrLoad calls and caches the
+	// loader errors.
+	loader loader[K, V]
+
+	// codec encodes and decodes the snapshots and the log records.
+	codec Codec
+
+	// wal records the changes to the write-ahead log. It is nil if no log
+	// path is given.
+	wal *wal[K, V]
+
+	// refresher reloads the data in the background.
+	refresher refresher[K, V]
+
+	// defaultTTL is the expiration duration of the data added with zero
+	// duration. Zero means that the data never expires.
+	defaultTTL time.Duration
+
+	// ttlJitter is the fraction that the TTLs are randomly changed by.
+	ttlJitter float64
+
+	// sliding reports whether Get pushes the expiration forward by the TTL.
+	sliding bool
+
+	// expiries orders the data with expiration by the expiration time.
+	expiries expiryQueue[K, V]
+
+	// clock tells the current time.
+	clock Clock
+
+	// config is the configuration that the cache is created with.
+	config Config
 }
 
-// Item is the cached data type.
-type Item struct {
+// TypedItem is the cached data type of TypedCache.
+type TypedItem[K comparable, V any] struct {
 	// Key is the value's key.
-	Key interface{}
+	Key K
 
 	// Val is the value of the cached data.
-	Val interface{}
+	Val V
+
+	// Expiration is the time that the data expires. The zero time means that
+	// the data never expires. It keeps the monotonic clock reading of the time
+	// it is computed from, so changing the wall clock does not make the data
+	// expire early or late.
+	Expiration time.Time
 
-	// Expiration is the amount of time to saved on memory.
-	Expiration int64
+	// cost is the cost of the data given by the weigher.
+	cost int64
+
+	// ttl is the expiration duration that the data is added with. It is zero
+	// if the data never expires.
+	ttl time.Duration
 }
 
 // New creates a new cache and returns it with error type. Capacity of the cache
-// needs to be more than zero.
-func New(cap int) (*Cache, error) {
-	if cap == 0 {
-		return nil, errZeroCapacity
-	}
-	if cap < 0 {
-		return nil, errNegCapacity
-	}
-	lst := list.New()
-	return &Cache{
-		cap: cap,
-		mu:  sync.Mutex{},
-		lst: lst,
-	}, nil
-}
-
-// Add saves data to cache if it is not saved yet. If the capacity is full,
-// the least-recently used one will be removed and new data will be added.
-// If you do not want to add an expired time for data, you need to pass 0.
-func (c *Cache) Add(key interface{}, val interface{}, exp time.Duration) error {
-	_, found := c.get(key)
-	if found {
-		return errKeyExist
+// needs to be more than zero. Optional behaviors can be enabled with opts. It
+// is a shorthand for NewWithOptions with WithCapacity.
+func New(cap int, opts ...Option) (*Cache, error) {
+	return NewTyped[any, any](cap, opts...)
+}
+
+// NewWithOptions creates a new cache configured by opts. The capacity is
+// given WithCapacity and it needs to be more than zero. All settings are
+// validated before the cache is created.
+func NewWithOptions(opts ...Option) (*Cache, error) {
+	return NewTypedWithOptions[any, any](opts...)
+}
+
+// NewTyped creates a new cache that stores values of type V with keys of type
+// K. Capacity of the cache needs to be more than zero. Optional behaviors can
+// be enabled with opts. cap overrides the capacity given WithCapacity.
+func NewTyped[K comparable, V any](cap int, opts ...Option) (*TypedCache[K, V], error) {
+	return NewTypedWithOptions[K, V](append(opts[:len(opts):len(opts)], WithCapacity(cap))...)
+}
+
+// NewTypedWithOptions creates a new cache that stores values of type V with
+// keys of type K. It works like NewWithOptions.
+func NewTypedWithOptions[K comparable, V any](opts ...Option) (*TypedCache[K, V], error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
 	}
-	item := Item{
-		Key:        key,
-		Val:        val,
-		Expiration: time.Now().Add(exp).UnixNano(),
+	onEvict, err := configFunc[func(K, V, EvictReason)]("eviction callback", cfg.OnEvict)
+	if err != nil {
+		return nil, err
 	}
-	if exp == 0 {
-		item.Expiration = 0
+	weigher, err := configFunc[func(K, V) int64]("weigher", cfg.Weigher)
+	if err != nil {
+		return nil, err
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.Len() == c.Cap() {
-		lruKey := c.getLRU()
-		c.delete(lruKey.Key)
+	loader, err := configFunc[func(K) (V, time.Duration, error)]("loader", cfg.Loader)
+	if err != nil {
+		return nil, err
+	}
+	c := &TypedCache[K, V]{
+		mu:     sync.RWMutex{},
+		items:  make(map[K]*entry[K, V]),
+		policy: newPolicy[K](cfg.Policy, cfg.Capacity),
+		config: cfg,
 	}
+	c.lst.init(cfg.Capacity)
+	c.cap.Store(int64(cfg.Capacity))
+	c.maxCost.Store(cfg.MaxCost)
+	c.loader.negativeTTL = cfg.NegativeTTL
+	c.codec = cfg.Codec
+	c.refresher.ahead = cfg.RefreshAhead
+	c.refresher.staleWindow = cfg.StaleWindow
+	c.defaultTTL = cfg.DefaultTTL
+	c.ttlJitter = cfg.TTLJitter
+	c.sliding = cfg.SlidingExpiration
+	c.clock = cfg.Clock
+	c.weigher = weigher
+	c.refresher.loader = loader
+	if cfg.WALPath != "" {
+		w, err := openWAL(c, cfg.WALPath, cfg.SyncPolicy, cfg.SyncInterval)
+		if err != nil {
+			return nil, err
+		}
+		c.wal = w
+		c.stats.reset()
+	}
+	c.onEvict = onEvict
+	if cfg.CleanupInterval > 0 {
+		c.janitor = newJanitor(cfg.CleanupInterval, c.ClearExpiredData)
+	}
+	return c, nil
+}
+
+// Config returns the effective configuration of the cache. The capacity and
+// the maximum cost reflect the changes made by Resize and ResizeCost, and the
+// callbacks are the ones registered by OnEvict, SetWeigher and SetLoader, if
+// they are called after the cache is created.
+func (c *TypedCache[K, V]) Config() Config {
+	cfg := c.config
+	cfg.Capacity = c.Cap()
+	cfg.MaxCost = c.MaxCost()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cfg.OnEvict, cfg.Weigher, cfg.Loader = nil, nil, nil
+	if c.onEvict != nil {
+		cfg.OnEvict = c.onEvict
+	}
+	if c.weigher != nil {
+		cfg.Weigher = c.weigher
+	}
+	if c.refresher.loader != nil {
+		cfg.Loader = c.refresher.loader
+	}
+	return cfg
+}
 
-	c.lst.PushFront(item)
-	c.len++
+// Add saves data to cache if it is not saved yet. If the capacity or the
+// maximum cost is full, the victims of the eviction policy, which is the
+// least-recently used one by default, will be removed and new data will be
+// added. If you do not want to add an expired time for data, you need to pass
+// 0. If the cache has a default TTL, 0 means the default TTL and NoExpiration
+// adds data that never expires. Expired data with the same key is replaced by
+// the new data.
+func (c *TypedCache[K, V]) Add(key K, val V, exp time.Duration) error {
+	item := c.newItem(key, val, exp)
+	c.mu.Lock()
+	defer c.unlock()
+	item.cost = c.weigh(key, val)
+	if err := c.checkCost(key, item.cost); err != nil {
+		return err
+	}
+	if _, found := c.lookup(key); found {
+		return keyError(key, ErrKeyExists)
+	}
+	c.insert(item)
 	return nil
 }
 
 // Get retrieves the data from list and returns it with bool information which
-// indicates whether found. If there is no such data in cache, it returns nil
-// and false.
-func (c *Cache) Get(key interface{}) (interface{}, bool) {
-	if c.Len() == 0 {
-		return nil, false
-	}
+// indicates whether found. If there is no such data in cache or the data is
+// expired, it returns the zero value of V and false. Expired data is removed
+// from the cache.
+//
+// If a loader is registered with SetLoader, Get also refreshes the data in
+// the background. WithRefreshAhead refreshes the data which passes the given
+// fraction of its TTL, and WithStaleWhileRevalidate returns the expired data
+// during the stale window while refreshing it. Only one refresh runs for a key
+// at a time.
+func (c *TypedCache[K, V]) Get(key K) (V, bool) {
+	var zero V
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	val, found := c.get(key)
-	if val == nil {
-		return nil, found
+	defer c.unlock()
+	e, found := c.get(key)
+	if !found {
+		c.stats.hit(false)
+		return zero, false
+	}
+	item := e.item
+	now := c.clock.Now()
+	switch {
+	case !item.expired(now):
+		if c.refreshDue(item, now) {
+			c.refresh(key)
+		}
+		c.slide(e, now)
+	case c.stale(item, now):
+		c.stats.staleHits.Add(1)
+		c.refresh(key)
+	default:
+		c.removeElement(e, ReasonExpired)
+		c.stats.hit(false)
+		return zero, false
+	}
+	c.stats.hit(true)
+	c.lst.moveToFront(e)
+	if c.policy != nil {
+		c.policy.access(key)
 	}
-	e := c.lst.Remove(val)
-	c.lst.PushFront(e)
-	return val.Value.(Item).Val, found
+	return item.Val, true
 }
 
 // Remove deletes the item from the cache. Updates the length of the cache
 // decrementing by one.
-func (c *Cache) Remove(key interface{}) error {
+func (c *TypedCache[K, V]) Remove(key K) error {
+	c.mu.Lock()
+	defer c.unlock()
 	if c.Len() == 0 {
-		return errEmptyCache
+		return ErrEmptyCache
 	}
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.delete(key)
+	c.delete(key, ReasonRemoved)
 	return nil
 }
 
 // Contains checks the given key and returns the information that it exists
 // on cache or not. Calling this function doesn't change the access order of
-// the cache.
-func (c *Cache) Contains(key interface{}) bool {
-	if c.Len() == 0 {
-		return false
-	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	_, found := c.get(key)
+// the cache, so it can run concurrently with other readers. Expired data is
+// reported as absent and removed from the cache.
+func (c *TypedCache[K, V]) Contains(key K) bool {
+	_, found := c.peek(key)
 	return found
 }
 
 // Clear deletes all items from the cache.
-func (c *Cache) Clear() {
+func (c *TypedCache[K, V]) Clear() {
+	c.loader.clearFailures(time.Time{})
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.clear()
 }
 
 // Keys returns all keys in cache. It does not change frequency of the item
-// access.
-func (c *Cache) Keys() []interface{} {
-	var keys []interface{}
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	for e := c.lst.Front(); e != nil; e = e.Next() {
-		keys = append(keys, e.Value.(Item).Key)
+// access, so it can run concurrently with other readers. Keys of the expired
+// data are excluded even if the data is not cleared yet.
+func (c *TypedCache[K, V]) Keys() []K {
+	var keys []K
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		if !e.item.expired(now) {
+			keys = append(keys, e.item.Key)
+		}
 	}
 
 	return keys
 }
 
-// Peek returns the given key without updating access frequency of the item.
-func (c *Cache) Peek(key interface{}) (interface{}, bool) {
-	if c.Len() == 0 {
-		return nil, false
-	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	val, found := c.get(key)
-	if !found {
-		return nil, found
-	}
-	return val.Value.(Item).Val, found
+// Peek returns the given key without updating access frequency of the item,
+// so it can run concurrently with other readers. If there is no such data in
+// cache or the data is expired, it returns the zero value of V and false.
+// Expired data is removed from the cache.
+func (c *TypedCache[K, V]) Peek(key K) (V, bool) {
+	item, found := c.peek(key)
+	c.stats.hit(found)
+	return item.Val, found
 }
 
-// RemoveOldest removes the least recently used one. Returns removed key, value,
-// and bool value that indicates whether remove operation is done successfully.
-func (c *Cache) RemoveOldest() (k interface{}, v interface{}, ok bool) {
+// RemoveOldest removes the least recently used one, or the next victim of the
+// eviction policy if it is not PolicyLRU. Returns removed key, value, and bool
+// value that indicates whether remove operation is done successfully.
+// If the cache is empty, zero values of K and V are returned.
+func (c *TypedCache[K, V]) RemoveOldest() (k K, v V, ok bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	k, v, ok = c.removeOldest()
+	defer c.unlock()
+	k, v, ok = c.removeOldest(ReasonRemoved)
 	return
 }
 
 // Resize changes the size of the capacity. If new capacity is lower than
-// existing capacity, the oldest items will be removed. It returns the number
+// existing capacity, the oldest items, or the victims of the eviction policy,
+// will be removed. It returns the number
 // of the removed oldest elements from the cache. If it is zero, means that
-// no data removed from the cache.
-func (c *Cache) Resize(size int) int {
+// no data removed from the cache. The size cannot be less than one; such sizes
+// are raised to one.
+func (c *TypedCache[K, V]) Resize(size int) int {
+	size = maxInt(size, 1)
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	diff := c.resize(size)
 	return diff
 }
 
-// Len returns length of the cache.
-func (c *Cache) Len() int {
-	return c.len
+// Len returns length of the cache. The expired data which is not cleared yet
+// is also counted. Use ActiveLen to count only the unexpired data.
+func (c *TypedCache[K, V]) Len() int {
+	return int(c.len.Load())
+}
+
+// ActiveLen returns the number of unexpired data in cache. Unlike Len, it
+// excludes the expired data that is not cleared yet. The expired data is
+// counted in the expiry queue, so it takes time proportional to the number of
+// expired data rather than the length of the cache.
+func (c *TypedCache[K, V]) ActiveLen() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Len() - c.expiries.countBefore(c.clock.Now())
 }
 
 // Cap returns capacity of the cache.
-func (c *Cache) Cap() int {
-	return c.cap
+func (c *TypedCache[K, V]) Cap() int {
+	return int(c.cap.Load())
 }
 
 // Replace changes the value of the given key, if the key exists. If the key
-// does not exist, it returns error. Calling Replace function does not change
-// the cache order.
-func (c *Cache) Replace(key interface{}, val interface{}) error {
+// does not exist or its data is expired, it returns error. Calling Replace function does not change
+// the cache order. If the new value exceeds the maximum cost, the victims of
+// the eviction policy are evicted, which may include the replaced data itself.
+func (c *TypedCache[K, V]) Replace(key K, val V) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	e, found := c.get(key)
+	defer c.unlock()
+	e, found := c.lookup(key)
 	if !found {
-		return errKeyNotExist
+		return keyError(key, ErrKeyNotFound)
 	}
-	e.Value = Item{
-		Key:        key,
-		Val:        val,
-		Expiration: e.Value.(Item).Expiration,
+	cost := c.weigh(key, val)
+	if err := c.checkCost(key, cost); err != nil {
+		return err
 	}
+	c.notify(e.item, ReasonReplaced)
+	c.stats.updates.Add(1)
+	c.cost.Add(cost - e.item.cost)
+	e.item.Val = val
+	e.item.cost = cost
+	c.logSet(e.item)
+	c.evictOverCost(ReasonCapacity)
 	return nil
 }
 
-// ClearExpiredData deletes the all expired data in cache.
-func (c *Cache) ClearExpiredData() {
+// ClearExpiredData deletes the all expired data in cache. The expired data is
+// found in the expiry queue, so it takes time proportional to the number of
+// expired data rather than the length of the cache.
+func (c *TypedCache[K, V]) ClearExpiredData() {
+	now := c.clock.Now()
+	c.loader.clearFailures(now)
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	l := c.Len()
 	if l == 0 {
 		return
 	}
 
-	now := time.Now().UnixNano()
 	c.clearExpiredData(now)
 }
 
-// UpdateVal updates the value of the given key. If there is no such a data, error
-// will be returned. Cache data order is updated after updating the value. It
-// returns updated item.
-func (c *Cache) UpdateVal(key interface{}, val interface{}) (Item, error) {
+// UpdateVal updates the value of the given key. If there is no such a data or
+// the data is expired, error will be returned. Cache data order is updated after updating the value. It
+// returns updated item. If the new value exceeds the maximum cost, the victims
+// of the eviction policy are evicted.
+func (c *TypedCache[K, V]) UpdateVal(key K, val V) (TypedItem[K, V], error) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.update(key, val, -1)
+	defer c.unlock()
+	if err := c.checkCost(key, c.weigh(key, val)); err != nil {
+		return TypedItem[K, V]{}, err
+	}
+	return c.update(key, func(item *TypedItem[K, V]) {
+		c.notify(*item, ReasonReplaced)
+		item.Val = val
+	})
 }
 
-// UpdateExpirationDate updates the expiration date of the given key. If there
-// is no such a data, error will be returned. Cache data order is updated after
-// updating the expiration time. It returns updated item.
-func (c *Cache) UpdateExpirationDate(key interface{}, exp time.Duration) (Item, error) {
+// UpdateExpirationDate updates the expiration date of the given key. The new
+// expiration is computed like Add does, so 0 means the default TTL, which is
+// no expiration unless WithDefaultTTL is given, and NoExpiration makes the
+// data never expire. KeepTTL keeps the current expiration. If there is no such
+// a data or the data is expired, error will be returned, so the expired data
+// cannot be brought back. Cache data order is updated after updating
+// the expiration time. It returns updated item.
+func (c *TypedCache[K, V]) UpdateExpirationDate(key K, exp time.Duration) (TypedItem[K, V], error) {
+	expiration, ttl := c.expiration(exp, c.clock.Now())
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	newExpTime := time.Now().Add(exp).Unix()
-	return c.update(key, nil, newExpTime)
+	defer c.unlock()
+	return c.update(key, func(item *TypedItem[K, V]) {
+		if exp != KeepTTL {
+			item.Expiration, item.ttl = expiration, ttl
+		}
+	})
 }
 
-// Expired returns true if the item expired.
-func (i Item) Expired() bool {
-	if i.Expiration == 0 {
-		return false
+// TTL returns the remaining lifetime of the given key. It returns NoExpiration
+// if the data never expires. If there is no such data in cache or the data is
+// expired, it returns 0 and false. It does not change the access order of the
+// cache, so it can run concurrently with other readers.
+func (c *TypedCache[K, V]) TTL(key K) (time.Duration, bool) {
+	item, found := c.peek(key)
+	if !found {
+		return 0, false
+	}
+	if item.Expiration.IsZero() {
+		return NoExpiration, true
 	}
-	return i.Expiration < time.Now().UnixNano()
+	ttl := item.Expiration.Sub(c.clock.Now())
+	if ttl < 0 {
+		return 0, false
+	}
+	return ttl, true
 }
 
-// get traverses the list from head to tail and looks at the given key at each
-// step. It can be considered data retrieve function for cache.
-func (c *Cache) get(key interface{}) (*list.Element, bool) {
-	for e := c.lst.Front(); e != nil; e = e.Next() {
-		if e.Value.(Item).Key == key {
-			return e, true
-		}
+// Close stops the background cleanup goroutine started by WithCleanupInterval
+// and waits for it to return. The cache is still usable after Close, but the
+// expired data is not cleared automatically anymore. If the cache has a
+// write-ahead log, Close flushes and closes it, and the later changes are not
+// recorded. It is safe to call Close multiple times.
+func (c *TypedCache[K, V]) Close() {
+	if c.janitor != nil {
+		c.janitor.close()
+	}
+	c.refresher.wg.Wait()
+	if c.wal != nil {
+		c.mu.Lock()
+		_ = c.wal.close()
+		c.mu.Unlock()
+	}
+}
+
+// Expired returns true if the item expired by the system time. The cache
+// checks the expiration with the clock given WithClock, so use the Expiration
+// field to check it against another clock.
+func (i TypedItem[K, V]) Expired() bool {
+	return i.expired(time.Now())
+}
+
+// expired reports whether the item is expired at the given time.
+func (i TypedItem[K, V]) expired(now time.Time) bool {
+	return !i.Expiration.IsZero() && now.After(i.Expiration)
+}
+
+// get looks up the given key in the index and returns its list entry. It can
+// be considered data retrieve function for cache.
+func (c *TypedCache[K, V]) get(key K) (*entry[K, V], bool) {
+	e, found := c.items[key]
+	return e, found
+}
+
+// lookup works like get, but it treats the expired data as absent and removes
+// it from the cache.
+func (c *TypedCache[K, V]) lookup(key K) (*entry[K, V], bool) {
+	e, found := c.get(key)
+	if !found {
+		return nil, false
+	}
+	if e.item.expired(c.clock.Now()) {
+		c.removeElement(e, ReasonExpired)
+		return nil, false
+	}
+	return e, true
+}
+
+// peek returns the item of the given key under the read lock. If the item is
+// expired, it takes the write lock and looks the key up again to remove it,
+// since the item may be replaced by a fresh one after the read lock is
+// released.
+func (c *TypedCache[K, V]) peek(key K) (TypedItem[K, V], bool) {
+	c.mu.RLock()
+	var item TypedItem[K, V]
+	e, found := c.get(key)
+	if found {
+		item = e.item
 	}
-	return nil, false
+	c.mu.RUnlock()
+	if !found || !item.expired(c.clock.Now()) {
+		return item, found
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if e, found = c.get(key); !found {
+		return TypedItem[K, V]{}, false
+	}
+	item = e.item
+	now := c.clock.Now()
+	if !c.retained(item, now) {
+		c.removeElement(e, ReasonExpired)
+		return TypedItem[K, V]{}, false
+	}
+	if item.expired(now) {
+		return TypedItem[K, V]{}, false
+	}
+	return item, true
 }
 
 // delete removes the cached data from the list.
-func (c *Cache) delete(key interface{}) {
-	v, found := c.get(key)
+func (c *TypedCache[K, V]) delete(key K, reason EvictReason) {
+	e, found := c.get(key)
 	if !found {
 		return
 	}
-	c.lst.Remove(v)
-	c.len--
+	c.removeElement(e, reason)
+}
+
+// removeElement removes the given entry from the list and the index.
+func (c *TypedCache[K, V]) removeElement(e *entry[K, V], reason EvictReason) {
+	c.unschedule(e)
+	item := c.lst.remove(e)
+	delete(c.items, item.Key)
+	c.len.Add(-1)
+	c.cost.Add(-item.cost)
+	if c.policy != nil {
+		c.policy.remove(item.Key, reason)
+	}
+	c.logRemove(item.Key)
+	c.notify(item, reason)
 }
 
-// getLRU returns least recently used item from list.
-func (c *Cache) getLRU() Item {
-	return c.lst.Back().Value.(Item)
+// victim returns the entry which should be evicted next. It is the least
+// recently used entry from list, unless another eviction policy is set.
+func (c *TypedCache[K, V]) victim() *entry[K, V] {
+	if c.policy != nil {
+		return c.items[c.policy.victim()]
+	}
+	return c.lst.back()
 }
 
-// clear removes all elements from the list.
-func (c *Cache) clear() {
-	var next *list.Element
-	for e := c.lst.Front(); e != nil; e = next {
-		next = e.Next()
-		c.lst.Remove(e)
-		c.len--
+// clear removes all entries from the list.
+func (c *TypedCache[K, V]) clear() {
+	var next *entry[K, V]
+	for e := c.lst.front(); e != nil; e = next {
+		next = c.lst.next(e)
+		c.len.Add(-1)
+		c.notify(c.lst.remove(e), ReasonCleared)
+	}
+	c.items = make(map[K]*entry[K, V])
+	c.expiries = nil
+	c.cost.Store(0)
+	if c.policy != nil {
+		c.policy.clear()
 	}
+	c.logClear()
 }
 
 // removeOldest removes the oldest data from the cache.
-func (c *Cache) removeOldest() (key interface{}, val interface{}, ok bool) {
+func (c *TypedCache[K, V]) removeOldest(reason EvictReason) (key K, val V, ok bool) {
 	if c.Len() == 0 {
-		return "", nil, false
+		return
 	}
-	oldest := c.getLRU()
-	key, val = oldest.Key, oldest.Val
-	c.delete(key)
+	oldest := c.victim()
+	key, val = oldest.item.Key, oldest.item.Val
+	c.removeElement(oldest, reason)
 	ok = true
 	return
 }
 
 // resize changes the capacity of the cache. It prunes the oldest elements from
 // the cache if the size is lower than length of the cache.
-func (c *Cache) resize(size int) int {
+func (c *TypedCache[K, V]) resize(size int) int {
 	var diff int
 	if size < c.Len() {
 		diff = c.Len() - size
 	}
+	if c.policy != nil {
+		c.policy.resize(size)
+	}
 
 	for i := 0; i < diff; i++ {
-		c.removeOldest()
+		c.removeOldest(ReasonResized)
 	}
-	c.cap = size
+	c.cap.Store(int64(size))
+	c.lst.setLimit(size)
 
 	return diff
 }
 
-// clearExpiredData removes the all expired data in cache.
-func (c *Cache) clearExpiredData(now int64) {
-	var next *list.Element
-	for e := c.lst.Front(); e != nil; e = next {
-		next = e.Next()
-		if exp := e.Value.(Item).Expiration; exp != 0 && exp < now {
-			c.lst.Remove(e)
-			c.len--
-		}
+// clearExpiredData removes the all expired data in cache by popping the
+// expiry queue until its earliest expiration is not before the cutoff.
+func (c *TypedCache[K, V]) clearExpiredData(now time.Time) {
+	cutoff := c.expiryCutoff(now)
+	for len(c.expiries) > 0 && c.expiries[0].expiration.Before(cutoff) {
+		c.removeElement(c.expiries[0].entry, ReasonExpired)
 	}
 }
 
-// update changes the val and/or expiration date.
-func (c *Cache) update(key interface{}, val interface{}, exp int64) (Item, error) {
-	var next *list.Element
-	for e := c.lst.Front(); e != nil; e = next {
-		next = e.Next()
-		if k := e.Value.(Item).Key; k == key {
-			if val == nil {
-				val = e.Value.(Item).Val
-			}
-			if exp == -1 {
-				exp = e.Value.(Item).Expiration
-			}
-
-			c.lst.Remove(e)
-			newItem := Item{
-				Key:        key,
-				Val:        val,
-				Expiration: exp,
-			}
-			c.lst.PushFront(newItem)
-			return newItem, nil
-		}
+// insert pushes the new item to the front of the list. If the capacity or the
+// maximum cost is full, the victims of the eviction policy are evicted first.
+// The key must not exist in the cache and the cost of the item must be set.
+func (c *TypedCache[K, V]) insert(item TypedItem[K, V]) {
+	for c.Len() > 0 && (c.Len() >= c.Cap() || c.overCost(item.cost)) {
+		c.removeElement(c.victim(), ReasonCapacity)
+	}
+
+	e := c.lst.pushFront(item)
+	c.items[item.Key] = e
+	c.schedule(e)
+	c.cost.Add(item.cost)
+	if c.policy != nil {
+		c.policy.add(item.Key)
+	}
+	c.len.Add(1)
+	c.stats.adds.Add(1)
+	c.logSet(item)
+}
+
+// set adds the item, or overwrites the existing item with the same key and
+// moves it to the front of the list.
+func (c *TypedCache[K, V]) set(item TypedItem[K, V]) error {
+	item.cost = c.weigh(item.Key, item.Val)
+	if err := c.checkCost(item.Key, item.cost); err != nil {
+		return err
+	}
+	e, found := c.lookup(item.Key)
+	if !found {
+		c.insert(item)
+		return nil
+	}
+
+	c.notify(e.item, ReasonReplaced)
+	c.stats.updates.Add(1)
+	c.cost.Add(item.cost - e.item.cost)
+	e.item = item
+	c.schedule(e)
+	c.lst.moveToFront(e)
+	if c.policy != nil {
+		c.policy.access(item.Key)
+	}
+	c.logSet(item)
+	c.evictOverCost(ReasonCapacity)
+	return nil
+}
+
+// update applies fn to the item of the given key and moves it to the front of
+// the list. The expired data is treated as absent like lookup does.
+func (c *TypedCache[K, V]) update(key K, fn func(item *TypedItem[K, V])) (TypedItem[K, V], error) {
+	e, found := c.lookup(key)
+	if !found {
+		return TypedItem[K, V]{}, keyError(key, ErrKeyNotFound)
+	}
+
+	oldCost := e.item.cost
+	fn(&e.item)
+	e.item.cost = c.weigh(key, e.item.Val)
+	newItem := e.item
+	c.stats.updates.Add(1)
+	c.cost.Add(newItem.cost - oldCost)
+	c.schedule(e)
+	c.lst.moveToFront(e)
+	if c.policy != nil {
+		c.policy.access(key)
 	}
-	return Item{}, errNoKey
+	c.logSet(newItem)
+	c.evictOverCost(ReasonCapacity)
+	return newItem, nil
 }
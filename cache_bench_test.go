@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchSizes are the cache sizes that every benchmark runs against.
+var benchSizes = []int{10, 10_000, 1_000_000}
+
+// newBenchCache creates a full cache with n integer keys. It is a helper
+// function to prevent code duplication.
+func newBenchCache(b *testing.B, n int) *Cache {
+	b.Helper()
+	c, err := New(n)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := c.Add(i, i, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return c
+}
+
+// runSizes runs fn as a sub-benchmark for each size in benchSizes on a
+// pre-filled cache.
+func runSizes(b *testing.B, fn func(b *testing.B, c *Cache, n int)) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("size=%d", n), func(b *testing.B) {
+			c := newBenchCache(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			fn(b, c, n)
+		})
+	}
+}
+
+func BenchmarkCache_Add(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			_ = c.Add(n+i, i, 0)
+		}
+	})
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Get(i % n)
+		}
+	})
+}
+
+func BenchmarkCache_Peek(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Peek(i % n)
+		}
+	})
+}
+
+func BenchmarkCache_Contains(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Contains(i % n)
+		}
+	})
+}
+
+func BenchmarkCache_Remove(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			key := i % n
+			_ = c.Remove(key)
+			b.StopTimer()
+			_ = c.Add(key, key, 0)
+			b.StartTimer()
+		}
+	})
+}
+
+func BenchmarkCache_Replace(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			_ = c.Replace(i%n, i)
+		}
+	})
+}
+
+func BenchmarkCache_UpdateVal(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			_, _ = c.UpdateVal(i%n, i)
+		}
+	})
+}
+
+func BenchmarkCache_UpdateExpirationDate(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			_, _ = c.UpdateExpirationDate(i%n, 0)
+		}
+	})
+}
+
+func BenchmarkCache_RemoveOldest(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			k, _, _ := c.RemoveOldest()
+			b.StopTimer()
+			_ = c.Add(k, k, 0)
+			b.StartTimer()
+		}
+	})
+}
+
+func BenchmarkCache_Keys(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Keys()
+		}
+	})
+}
+
+func BenchmarkCache_Len(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Len()
+		}
+	})
+}
+
+func BenchmarkCache_Cap(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Cap()
+		}
+	})
+}
+
+func BenchmarkCache_Resize(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			if i%2 == 0 {
+				c.Resize(n + 1)
+			} else {
+				c.Resize(n)
+			}
+		}
+	})
+}
+
+func BenchmarkCache_ClearExpiredData(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.ClearExpiredData()
+		}
+	})
+}
+
+func BenchmarkCache_Clear(b *testing.B) {
+	runSizes(b, func(b *testing.B, c *Cache, n int) {
+		for i := 0; i < b.N; i++ {
+			c.Clear()
+			b.StopTimer()
+			for j := 0; j < n; j++ {
+				_ = c.Add(j, j, 0)
+			}
+			b.StartTimer()
+		}
+	})
+}
+
+// BenchmarkCache_ClearExpiredDataOnePercent measures a sweep of a full cache
+// where 1% of the data is expired and the rest expires in an hour.
+func BenchmarkCache_ClearExpiredDataOnePercent(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("size=%d", n), func(b *testing.B) {
+			c, err := New(n)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				_ = c.Add(i, i, time.Hour)
+			}
+			expiring := n / 100
+			if expiring == 0 {
+				expiring = 1
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for j := 0; j < expiring; j++ {
+					key := j * (n / expiring)
+					_ = c.Remove(key)
+					_ = c.Add(key, key, -time.Millisecond)
+				}
+				b.StartTimer()
+				c.ClearExpiredData()
+			}
+			b.StopTimer()
+			if l := c.Len(); l != n-expiring {
+				b.Fatalf("Len = %d, want %d", l, n-expiring)
+			}
+		})
+	}
+}
@@ -55,9 +55,9 @@ func addItemsWithExp(t *testing.T, cache *Cache, pairs [][]any) {
 // function to prevent code duplication.
 func findItem(t *testing.T, c *Cache, key any) (Item, bool) {
 	t.Helper()
-	for e := c.lst.Front(); e != nil; e = e.Next() {
-		if item := e.Value.(Item); item.Key == key {
-			return item, true
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		if e.item.Key == key {
+			return e.item, true
 		}
 	}
 	return Item{}, false
@@ -67,19 +67,19 @@ func findItem(t *testing.T, c *Cache, key any) (Item, bool) {
 // of items) in cache. It is a helper function to prevent code duplication.
 func cmpCacheListOrder(t *testing.T, c *Cache, order []any) {
 	t.Helper()
-	if c.lst.Len() != len(order) {
-		t.Errorf("expect cache list to have length, %v, want %v", c.lst.Len(), len(order))
+	if c.lst.len != len(order) {
+		t.Errorf("expect cache list to have length, %v, want %v", c.lst.len, len(order))
 	}
 
 	i := 0
-	e := c.lst.Front()
+	e := c.lst.front()
 	for e != nil {
 		o := order[i]
-		k := e.Value.(Item).Key
+		k := e.item.Key
 		if !reflect.DeepEqual(k, o) {
 			t.Errorf("incorrect key order, got %v, want %v at index %d", k, o, i)
 		}
-		e = e.Next()
+		e = c.lst.next(e)
 		i++
 	}
 }
@@ -118,17 +118,14 @@ func TestCache_Add(t *testing.T) {
 		c := createCache(t, tt.capacity)
 		t.Run(tt.name, func(t *testing.T) {
 			for _, pair := range tt.addPairs {
-				var (
-					wantErr error = nil
-					wantExp int64 = 0
-				)
-				err := c.Add(pair[0], pair[1], time.Duration(wantExp))
+				var wantErr error = nil
+				err := c.Add(pair[0], pair[1], 0)
 				if !errors.Is(err, wantErr) {
 					t.Errorf("unexpected error, got error %v, want %v", err, wantErr)
 					return
 				}
-				if exp := c.lst.Front().Value.(Item).Expiration; exp != wantExp {
-					t.Errorf("unexpected expiration, got %v want %v", exp, wantExp)
+				if exp := c.lst.front().item.Expiration; !exp.IsZero() {
+					t.Errorf("unexpected expiration, got %v want the zero time", exp)
 				}
 			}
 			if c.Len() != tt.wantLength {
@@ -152,13 +149,13 @@ func TestCache_New(t *testing.T) {
 			name:     "returns error when provided capacity == 0",
 			capacity: 0,
 			want:     nil,
-			wantErr:  errZeroCapacity,
+			wantErr:  ErrZeroCapacity,
 		},
 		{
 			name:     "returns error when provided capacity < 0",
 			capacity: -1,
 			want:     nil,
-			wantErr:  errNegCapacity,
+			wantErr:  ErrNegativeCapacity,
 		},
 		{
 			name:     "creates cache with given capacity, when capacity > 0",
@@ -181,6 +178,52 @@ func TestCache_New(t *testing.T) {
 	}
 }
 
+func TestNewTyped(t *testing.T) {
+	c, err := NewTyped[string, int](2)
+	if err != nil {
+		t.Fatalf("NewTyped() error = %v", err)
+	}
+	if _, err := NewTyped[string, int](0); !errors.Is(err, ErrZeroCapacity) {
+		t.Errorf("NewTyped() error = %v, want %v", err, ErrZeroCapacity)
+	}
+
+	if got, found := c.Get(k); found || got != 0 {
+		t.Errorf("Get() on empty cache = (%v, %v), want (0, false)", got, found)
+	}
+	if err := c.Add(k, 1, 0); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := c.Add(k+k, 2, 0); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if got, found := c.Get(k); !found || got != 1 {
+		t.Errorf("Get() = (%v, %v), want (1, true)", got, found)
+	}
+	if got, found := c.Peek("nonexistent"); found || got != 0 {
+		t.Errorf("Peek() = (%v, %v), want (0, false)", got, found)
+	}
+	item, err := c.UpdateVal(k+k, 3)
+	if err != nil {
+		t.Fatalf("UpdateVal() error = %v", err)
+	}
+	if item.Key != k+k || item.Val != 3 {
+		t.Errorf("UpdateVal() = %v, want {%v 3 0}", item, k+k)
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{k + k, k}) {
+		t.Errorf("Keys() = %v, want %v", keys, []string{k + k, k})
+	}
+
+	gotKey, gotVal, ok := c.RemoveOldest()
+	if !ok || gotKey != k || gotVal != 1 {
+		t.Errorf("RemoveOldest() = (%v, %v, %v), want (%v, 1, true)", gotKey, gotVal, ok, k)
+	}
+	c.Clear()
+	gotKey, gotVal, ok = c.RemoveOldest()
+	if ok || gotKey != "" || gotVal != 0 {
+		t.Errorf("RemoveOldest() on empty cache = (%q, %v, %v), want (\"\", 0, false)", gotKey, gotVal, ok)
+	}
+}
+
 func TestCache_Get(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -242,8 +285,8 @@ func TestCache_Get(t *testing.T) {
 					t.Errorf("cache.Get() = %v, want %v", got, want)
 				}
 			}
-			if c.Len() != c.lst.Len() {
-				t.Errorf("incorrect cache length, want %v, got %v", c.Len(), c.lst.Len())
+			if c.Len() != c.lst.len {
+				t.Errorf("incorrect cache length, want %v, got %v", c.Len(), c.lst.len)
 			}
 			if tt.wantKeysListOrder != nil {
 				cmpCacheListOrder(t, c, tt.wantKeysListOrder)
@@ -267,7 +310,7 @@ func TestCache_Remove(t *testing.T) {
 			capacity:       1,
 			addPairs:       [][]any{},
 			removeKeys:     []any{k},
-			wantErrs:       []error{errEmptyCache},
+			wantErrs:       []error{ErrEmptyCache},
 			checkLength:    false,
 			getRemovedKeys: false,
 		},
@@ -417,13 +460,13 @@ func TestCache_Clear(t *testing.T) {
 			if c.Len() != 0 {
 				t.Errorf("expected length s %v, got %v", 0, c.Len())
 			}
-			if c.lst.Len() != 0 {
-				t.Errorf("expected length of c.lst.Len() is %v, got %v", 0, c.lst.Len())
+			if c.lst.len != 0 {
+				t.Errorf("expected length of c.lst.len is %v, got %v", 0, c.lst.len)
 			}
 			if tt.checkListFront {
-				if c.lst.Front() != nil {
-					f := c.lst.Front().Value.(Item)
-					t.Errorf("expected c.lst.Front() to be nil, got %s-%s", f.Key, f.Val)
+				if c.lst.front() != nil {
+					f := c.lst.front().item
+					t.Errorf("expected c.lst.front() to be nil, got %s-%s", f.Key, f.Val)
 				}
 			}
 		})
@@ -537,10 +580,10 @@ func TestCache_RemoveOldest(t *testing.T) {
 		wantKeysListOrder []any
 	}{
 		{
-			name:              `returns ("", nil, false) for empty cache`,
+			name:              `returns (nil, nil, false) for empty cache`,
 			capacity:          1,
 			addPairs:          [][]any{},
-			want:              []any{"", nil, false},
+			want:              []any{nil, nil, false},
 			wantLength:        0,
 			wantKeysListOrder: nil,
 		},
@@ -653,6 +696,33 @@ func TestCache_Resize(t *testing.T) {
 	}
 }
 
+func TestCache_ResizeBelowOne(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "raises zero size to one", size: 0},
+		{name: "raises negative size to one", size: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 3)
+			addItems(t, c, [][]any{{k, v}, {k + k, v + v}})
+			if got := c.Resize(tt.size); got != 1 {
+				t.Errorf("unexpected diff, got %v, want %v", got, 1)
+			}
+			if c.Cap() != 1 {
+				t.Errorf("unexpected post resize capacity, got %v, want %v", c.Cap(), 1)
+			}
+			if err := c.Add(k+k+k, v+v+v, 0); err != nil {
+				t.Fatal(err)
+			}
+			cmpCacheListOrder(t, c, []any{k + k + k})
+			checkInvariants(t, c)
+		})
+	}
+}
+
 func TestCache_Len(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -731,7 +801,7 @@ func TestCache_Replace(t *testing.T) {
 			capacity:          3,
 			addPairs:          [][]any{{k, v}, {k + k, v + v}, {k + k + k, v + v + v}},
 			replacePair:       []any{k + v, k + v},
-			wantErr:           errKeyNotExist,
+			wantErr:           ErrKeyNotFound,
 			wantKeysListOrder: nil,
 		},
 	}
@@ -823,7 +893,7 @@ func TestCache_UpdateVal(t *testing.T) {
 			capacity:          3,
 			addPairs:          [][]any{},
 			updatePairs:       [][]any{{k, v}},
-			wantErrs:          []error{errNoKey},
+			wantErrs:          []error{ErrKeyNotFound},
 			wantKeysListOrder: nil,
 		},
 		{
@@ -831,7 +901,7 @@ func TestCache_UpdateVal(t *testing.T) {
 			capacity:          3,
 			addPairs:          [][]any{{k, v, time.Hour}},
 			updatePairs:       [][]any{{"nonexistant", nil}},
-			wantErrs:          []error{errNoKey},
+			wantErrs:          []error{ErrKeyNotFound},
 			wantKeysListOrder: nil,
 		},
 		{
@@ -888,7 +958,7 @@ func TestCache_UpdateExpirationDate(t *testing.T) {
 			capacity:          3,
 			addPairs:          [][]any{},
 			updatePairs:       [][]any{{k, time.Minute}},
-			wantErrs:          []error{errNoKey},
+			wantErrs:          []error{ErrKeyNotFound},
 			wantKeysListOrder: nil,
 		},
 		{
@@ -896,7 +966,7 @@ func TestCache_UpdateExpirationDate(t *testing.T) {
 			capacity:          3,
 			addPairs:          [][]any{{k, v, time.Hour}},
 			updatePairs:       [][]any{{"nonexistant", time.Minute}},
-			wantErrs:          []error{errNoKey},
+			wantErrs:          []error{ErrKeyNotFound},
 			wantKeysListOrder: nil,
 		},
 		{
@@ -929,7 +999,7 @@ func TestCache_UpdateExpirationDate(t *testing.T) {
 				if wantErr == nil && newItem.Val != oldItem.Val {
 					t.Errorf("unexpected updated item value, got %v, want %v", newItem.Val, oldItem.Val)
 				}
-				if wantErr == nil && newItem.Expiration == oldItem.Expiration {
+				if wantErr == nil && newItem.Expiration.Equal(oldItem.Expiration) {
 					t.Errorf("expected updated item expiration time %v, got %v", oldItem.Expiration, newItem.Expiration)
 				}
 			}
@@ -940,6 +1010,104 @@ func TestCache_UpdateExpirationDate(t *testing.T) {
 	}
 }
 
+func TestCache_ReadExpiredData(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(c *Cache, key any) bool
+	}{
+		{
+			name: "Get treats expired data as absent",
+			read: func(c *Cache, key any) bool {
+				val, found := c.Get(key)
+				return found || val != nil
+			},
+		},
+		{
+			name: "Peek treats expired data as absent",
+			read: func(c *Cache, key any) bool {
+				val, found := c.Peek(key)
+				return found || val != nil
+			},
+		},
+		{
+			name: "Contains treats expired data as absent",
+			read: func(c *Cache, key any) bool {
+				return c.Contains(key)
+			},
+		},
+	}
+	for _, tt := range tests {
+		c := createCache(t, 3)
+		addItemsWithExp(t, c, [][]any{{k, v, time.Hour}, {k + k, v + v, -1 * time.Hour}})
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.read(c, k+k) {
+				t.Errorf("expected expired key %v to be absent", k+k)
+			}
+			if !tt.read(c, k) {
+				t.Errorf("expected unexpired key %v to be present", k)
+			}
+			if c.Len() != 1 {
+				t.Errorf("expected expired data to be removed, got length %v, want %v", c.Len(), 1)
+			}
+			cmpCacheListOrder(t, c, []any{k})
+		})
+	}
+}
+
+func TestCache_AddExpiredKey(t *testing.T) {
+	c := createCache(t, 2)
+	addItemsWithExp(t, c, [][]any{{k, v, -1 * time.Hour}})
+	if err := c.Add(k, v+v, 0); err != nil {
+		t.Fatalf("unexpected error, got %v, want %v", err, nil)
+	}
+	if got, _ := c.Get(k); got != v+v {
+		t.Errorf("cache.Get() = %v, want %v", got, v+v)
+	}
+	if c.Len() != 1 {
+		t.Errorf("unexpected length, got %v, want %v", c.Len(), 1)
+	}
+}
+
+func TestCache_ExpiredKeysAndActiveLen(t *testing.T) {
+	tests := []struct {
+		name          string
+		addPairs      [][]any
+		wantKeys      []any
+		wantLength    int
+		wantActiveLen int
+	}{
+		{
+			name:          "excludes nothing when there is no expired data",
+			addPairs:      [][]any{{k, v, time.Hour}, {k + k, v + v, 0 * time.Hour}},
+			wantKeys:      []any{k + k, k},
+			wantLength:    2,
+			wantActiveLen: 2,
+		},
+		{
+			name:          "excludes expired data which is not cleared yet",
+			addPairs:      [][]any{{k, v, time.Hour}, {k + k, v + v, -1 * time.Hour}, {k + k + k, v + v + v, 0 * time.Hour}},
+			wantKeys:      []any{k + k + k, k},
+			wantLength:    3,
+			wantActiveLen: 2,
+		},
+	}
+	for _, tt := range tests {
+		c := createCache(t, 3)
+		addItemsWithExp(t, c, tt.addPairs)
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Keys(); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("cache.Keys() = %v, want %v", got, tt.wantKeys)
+			}
+			if got := c.Len(); got != tt.wantLength {
+				t.Errorf("cache.Len() = %v, want %v", got, tt.wantLength)
+			}
+			if got := c.ActiveLen(); got != tt.wantActiveLen {
+				t.Errorf("cache.ActiveLen() = %v, want %v", got, tt.wantActiveLen)
+			}
+		})
+	}
+}
+
 func TestItem_NotExpired(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -971,9 +1139,9 @@ func TestItem_NotExpired(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		var exp int64
+		var exp time.Time
 		if tt.duration != 0 {
-			exp = time.Now().Add(tt.duration).UnixNano()
+			exp = time.Now().Add(tt.duration)
 		}
 		item := Item{
 			Key:        tt.key,
@@ -985,3 +1153,82 @@ func TestItem_NotExpired(t *testing.T) {
 		}
 	}
 }
+
+func TestCache_ZeroAllocs(t *testing.T) {
+	const n = 100
+	tests := []struct {
+		name string
+		fn   func(c *TypedCache[int, int], i int)
+	}{
+		{
+			name: "Get hit",
+			fn:   func(c *TypedCache[int, int], i int) { c.Get(i % n) },
+		},
+		{
+			name: "Get miss",
+			fn:   func(c *TypedCache[int, int], i int) { c.Get(-1) },
+		},
+		{
+			name: "Peek",
+			fn:   func(c *TypedCache[int, int], i int) { c.Peek(i % n) },
+		},
+		{
+			name: "Contains",
+			fn:   func(c *TypedCache[int, int], i int) { c.Contains(i % n) },
+		},
+		{
+			name: "Replace",
+			fn:   func(c *TypedCache[int, int], i int) { _ = c.Replace(i%n, i) },
+		},
+		{
+			name: "UpdateVal",
+			fn:   func(c *TypedCache[int, int], i int) { _, _ = c.UpdateVal(i%n, i) },
+		},
+		{
+			name: "UpdateExpirationDate",
+			fn:   func(c *TypedCache[int, int], i int) { _, _ = c.UpdateExpirationDate(i%n, time.Hour) },
+		},
+		{
+			name: "AddOrReplace existing key",
+			fn:   func(c *TypedCache[int, int], i int) { _ = c.AddOrReplace(i%n, i, time.Hour) },
+		},
+		{
+			name: "GetOrAdd existing key",
+			fn:   func(c *TypedCache[int, int], i int) { _, _, _ = c.GetOrAdd(i%n, i, 0) },
+		},
+		{
+			name: "CompareAndSwap",
+			fn:   func(c *TypedCache[int, int], i int) { _, _ = c.CompareAndSwap(i%n, i%n, i%n) },
+		},
+		{
+			name: "Add to full cache",
+			fn:   func(c *TypedCache[int, int], i int) { _ = c.Add(n+i, i, 0) },
+		},
+		{
+			name: "Add with expiration to full cache",
+			fn:   func(c *TypedCache[int, int], i int) { _ = c.Add(n+i, i, time.Hour) },
+		},
+	}
+	policies := []Policy{PolicyLRU, PolicyLFU, PolicyFIFO, PolicyARC, Policy2Q, PolicyTinyLFU}
+	for _, p := range policies {
+		for _, tt := range tests {
+			t.Run(p.String()+"/"+tt.name, func(t *testing.T) {
+				c, err := NewTyped[int, int](n, WithPolicy(p))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < n; i++ {
+					_ = c.Add(i, i, time.Duration(i%2)*time.Hour)
+				}
+				i := 0
+				allocs := testing.AllocsPerRun(1000, func() {
+					tt.fn(c, i)
+					i++
+				})
+				if allocs != 0 {
+					t.Errorf("unexpected allocations, got %v, want 0", allocs)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Package cachetest provides utilities for testing the code that uses the cache.
+
+Clock is a fake clock that is advanced manually, so the expiration of the data
+can be tested without sleeping.
+
+	clock := cachetest.NewClock(time.Now())
+	c, err := cache.New(5, cache.WithClock(clock))
+	c.Add("foo", "bar", time.Minute)
+	clock.Advance(2 * time.Minute)
+	_, found := c.Get("foo") // found is false
+*/
+package cachetest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock that implements cache.Clock. Its time changes only by
+// calling Advance or Set. It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a fake clock that starts at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d. Negative d moves it backward.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set changes the current time of the clock to now.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
@@ -0,0 +1,52 @@
+package cachetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache"
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+var _ cache.Clock = (*cachetest.Clock)(nil)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := cachetest.NewClock(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("Now = %v, want %v", got, start)
+	}
+	clock.Advance(time.Hour)
+	if got := clock.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now = %v, want %v", got, start.Add(time.Hour))
+	}
+	clock.Set(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("Now = %v, want %v", got, start)
+	}
+}
+
+func TestClock_Expiration(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := cache.New(5, cache.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Add("foo", "bar", time.Minute)
+
+	clock.Advance(59 * time.Second)
+	if ttl, found := c.TTL("foo"); !found || ttl != time.Second {
+		t.Errorf("TTL = (%v, %v), want (%v, true)", ttl, found, time.Second)
+	}
+	if got := c.ActiveLen(); got != 1 {
+		t.Errorf("ActiveLen = %d, want 1", got)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, found := c.Get("foo"); found {
+		t.Error("data is found after it expires")
+	}
+	if got := c.Len(); got != 0 {
+		t.Errorf("Len = %d, want 0", got)
+	}
+}
@@ -0,0 +1,20 @@
+package cache
+
+import "time"
+
+// Clock tells the current time to the cache. The expiration of the data is
+// computed and checked with it, so tests can control the time instead of
+// sleeping. The cachetest package provides a fake clock that is advanced
+// manually.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock is the clock that returns the system time with time.Now. It is
+// the default clock of the cache.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,139 @@
+package cache
+
+import "time"
+
+// GetOrAdd returns the value of the given key if it exists. Otherwise, it adds
+// the given value with the expiration duration and returns it. The loaded
+// result is true if the value is found in the cache. The check and the add are
+// done atomically. Error is returned only if the cost of the value exceeds the
+// maximum cost.
+func (c *TypedCache[K, V]) GetOrAdd(key K, val V, exp time.Duration) (actual V, loaded bool, err error) {
+	c.mu.Lock()
+	defer c.unlock()
+	e, found := c.lookup(key)
+	c.stats.hit(found)
+	if found {
+		c.slide(e, c.clock.Now())
+		c.lst.moveToFront(e)
+		if c.policy != nil {
+			c.policy.access(key)
+		}
+		return e.item.Val, true, nil
+	}
+	if err := c.add(c.newItem(key, val, exp)); err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return val, false, nil
+}
+
+// AddOrReplace adds the data with the expiration duration, or replaces the
+// value and the expiration of the existing data. If exp is KeepTTL, the
+// expiration of the existing data is kept. Cache data order is updated in both
+// cases. Error is returned only if the cost of the value exceeds the maximum
+// cost.
+func (c *TypedCache[K, V]) AddOrReplace(key K, val V, exp time.Duration) error {
+	item := c.newItem(key, val, exp)
+	c.mu.Lock()
+	defer c.unlock()
+	if e, found := c.lookup(key); found && exp == KeepTTL {
+		item.Expiration, item.ttl = e.item.Expiration, e.item.ttl
+	}
+	return c.set(item)
+}
+
+// Compute atomically changes the value of the given key. fn is called with the
+// current value and whether the key exists, and it returns the new value and
+// whether the key should be kept. If keep is false, the existing data is
+// removed. Otherwise, the new value replaces the existing one without changing
+// its expiration, or it is added with the default TTL. Compute returns the new
+// value, or the zero value if the key is not kept.
+//
+// fn is called while the cache is locked, so it must not call the methods of
+// the cache.
+func (c *TypedCache[K, V]) Compute(key K, fn func(old V, exists bool) (val V, keep bool)) (V, error) {
+	var zero, old V
+	c.mu.Lock()
+	defer c.unlock()
+	e, exists := c.lookup(key)
+	if exists {
+		old = e.item.Val
+	}
+	val, keep := fn(old, exists)
+	switch {
+	case !keep:
+		if exists {
+			c.removeElement(e, ReasonRemoved)
+		}
+		return zero, nil
+	case exists:
+		if err := c.swap(key, val); err != nil {
+			return zero, err
+		}
+	default:
+		if err := c.add(c.newItem(key, val, 0)); err != nil {
+			return zero, err
+		}
+	}
+	return val, nil
+}
+
+// CompareAndSwap replaces the value of the given key with new if its current
+// value is equal to old. It returns whether the value is swapped. Error is
+// returned only if the cost of new exceeds the maximum cost. The values are
+// compared with ==, so it panics if V is not a comparable type.
+func (c *TypedCache[K, V]) CompareAndSwap(key K, old, new V) (bool, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	e, found := c.lookup(key)
+	if !found || !equal(e.item.Val, old) {
+		return false, nil
+	}
+	if err := c.swap(key, new); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CompareAndDelete removes the data of the given key if its current value is
+// equal to old. It returns whether the data is removed. The values are
+// compared with ==, so it panics if V is not a comparable type.
+func (c *TypedCache[K, V]) CompareAndDelete(key K, old V) bool {
+	c.mu.Lock()
+	defer c.unlock()
+	e, found := c.lookup(key)
+	if !found || !equal(e.item.Val, old) {
+		return false
+	}
+	c.removeElement(e, ReasonRemoved)
+	return true
+}
+
+// add weighs and inserts the new item. The key must not exist in the cache.
+func (c *TypedCache[K, V]) add(item TypedItem[K, V]) error {
+	item.cost = c.weigh(item.Key, item.Val)
+	if err := c.checkCost(item.Key, item.cost); err != nil {
+		return err
+	}
+	c.insert(item)
+	return nil
+}
+
+// swap replaces the value of the existing key and moves it to the front of
+// the list. The expiration is not changed.
+func (c *TypedCache[K, V]) swap(key K, val V) error {
+	if err := c.checkCost(key, c.weigh(key, val)); err != nil {
+		return err
+	}
+	_, err := c.update(key, func(item *TypedItem[K, V]) {
+		c.notify(*item, ReasonReplaced)
+		item.Val = val
+	})
+	return err
+}
+
+// equal reports whether a and b are equal. It panics if V is not a comparable
+// type.
+func equal[V any](a, b V) bool {
+	return any(a) == any(b)
+}
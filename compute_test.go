@@ -0,0 +1,385 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_GetOrAdd(t *testing.T) {
+	tests := []struct {
+		name       string
+		addPairs   [][]any
+		wantActual any
+		wantLoaded bool
+	}{
+		{
+			name:       "returns existing data",
+			addPairs:   [][]any{{k, v, 0 * time.Hour}},
+			wantActual: v,
+			wantLoaded: true,
+		},
+		{
+			name:       "adds missing data",
+			wantActual: v + v,
+			wantLoaded: false,
+		},
+		{
+			name:       "replaces expired data",
+			addPairs:   [][]any{{k, v, -1 * time.Hour}},
+			wantActual: v + v,
+			wantLoaded: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			addItemsWithExp(t, c, tt.addPairs)
+			actual, loaded, err := c.GetOrAdd(k, v+v, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.wantActual || loaded != tt.wantLoaded {
+				t.Errorf("got (%v, %v), want (%v, %v)", actual, loaded, tt.wantActual, tt.wantLoaded)
+			}
+			if val, _ := c.Peek(k); val != tt.wantActual {
+				t.Errorf("cached value = %v, want %v", val, tt.wantActual)
+			}
+		})
+	}
+}
+
+func TestCache_AddOrReplace(t *testing.T) {
+	c := createCache(t, 2)
+	addItems(t, c, [][]any{{k, v}, {k + k, v + v}})
+
+	if err := c.AddOrReplace(k, v+v+v, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	item, _ := findItem(t, c, k)
+	if item.Val != v+v+v || item.Expiration.IsZero() {
+		t.Errorf("item = %+v, want replaced value with expiration", item)
+	}
+	cmpCacheListOrder(t, c, []any{k, k + k})
+
+	if err := c.AddOrReplace(k+k+k, v, 0); err != nil {
+		t.Fatal(err)
+	}
+	cmpCacheListOrder(t, c, []any{k + k + k, k})
+}
+
+func TestCache_Compute(t *testing.T) {
+	tests := []struct {
+		name      string
+		addPairs  [][]any
+		fn        func(old any, exists bool) (any, bool)
+		wantVal   any
+		wantFound bool
+	}{
+		{
+			name:     "changes existing data",
+			addPairs: [][]any{{k, 1, 0 * time.Hour}},
+			fn: func(old any, exists bool) (any, bool) {
+				return old.(int) + 1, true
+			},
+			wantVal:   2,
+			wantFound: true,
+		},
+		{
+			name: "adds missing data",
+			fn: func(old any, exists bool) (any, bool) {
+				if exists {
+					return nil, false
+				}
+				return 1, true
+			},
+			wantVal:   1,
+			wantFound: true,
+		},
+		{
+			name:     "removes data that is not kept",
+			addPairs: [][]any{{k, 1, 0 * time.Hour}},
+			fn: func(old any, exists bool) (any, bool) {
+				return nil, false
+			},
+			wantFound: false,
+		},
+		{
+			name:     "treats expired data as missing",
+			addPairs: [][]any{{k, 1, -1 * time.Hour}},
+			fn: func(old any, exists bool) (any, bool) {
+				if exists {
+					return old, true
+				}
+				return 10, true
+			},
+			wantVal:   10,
+			wantFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			addItemsWithExp(t, c, tt.addPairs)
+			val, err := c.Compute(k, tt.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val != tt.wantVal {
+				t.Errorf("val = %v, want %v", val, tt.wantVal)
+			}
+			got, found := c.Peek(k)
+			if found != tt.wantFound || got != tt.wantVal {
+				t.Errorf("Peek = (%v, %v), want (%v, %v)", got, found, tt.wantVal, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCache_ComputeKeepsExpiration(t *testing.T) {
+	c := createCache(t, 5)
+	addItemsWithExp(t, c, [][]any{{k, 1, time.Hour}})
+	before, _ := findItem(t, c, k)
+	if _, err := c.Compute(k, func(old any, exists bool) (any, bool) {
+		return 2, true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := findItem(t, c, k)
+	if !after.Expiration.Equal(before.Expiration) {
+		t.Errorf("expiration = %v, want %v", after.Expiration, before.Expiration)
+	}
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	tests := []struct {
+		name        string
+		addPairs    [][]any
+		old         any
+		wantSwapped bool
+		wantVal     any
+	}{
+		{
+			name:        "swaps equal value",
+			addPairs:    [][]any{{k, v, 0 * time.Hour}},
+			old:         v,
+			wantSwapped: true,
+			wantVal:     v + v,
+		},
+		{
+			name:        "keeps different value",
+			addPairs:    [][]any{{k, v, 0 * time.Hour}},
+			old:         v + v + v,
+			wantSwapped: false,
+			wantVal:     v,
+		},
+		{
+			name:        "does not add missing data",
+			old:         nil,
+			wantSwapped: false,
+			wantVal:     nil,
+		},
+		{
+			name:        "does not swap expired data",
+			addPairs:    [][]any{{k, v, -1 * time.Hour}},
+			old:         v,
+			wantSwapped: false,
+			wantVal:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			addItemsWithExp(t, c, tt.addPairs)
+			swapped, err := c.CompareAndSwap(k, tt.old, v+v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if swapped != tt.wantSwapped {
+				t.Errorf("swapped = %v, want %v", swapped, tt.wantSwapped)
+			}
+			if val, _ := c.Peek(k); val != tt.wantVal {
+				t.Errorf("val = %v, want %v", val, tt.wantVal)
+			}
+		})
+	}
+}
+
+func TestCache_CompareAndDelete(t *testing.T) {
+	c := createCache(t, 5)
+	addItems(t, c, [][]any{{k, v}})
+
+	if c.CompareAndDelete(k, v+v) {
+		t.Error("data with a different value is deleted")
+	}
+	if !c.CompareAndDelete(k, v) {
+		t.Error("data with the same value is not deleted")
+	}
+	if c.Contains(k) {
+		t.Error("deleted data is still in the cache")
+	}
+	if c.CompareAndDelete(k, v) {
+		t.Error("missing data is deleted")
+	}
+}
+
+func TestCache_ComputeCostTooLarge(t *testing.T) {
+	c, err := NewTyped[string, string](5, WithMaxCost(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetWeigher(func(key, val string) int64 { return int64(len(val)) })
+	if err := c.Add(k, v, 0); err != nil {
+		t.Fatal(err)
+	}
+	large := v + v
+
+	if _, _, err := c.GetOrAdd(k+k, large, 0); !errors.Is(err, ErrCostTooLarge) {
+		t.Errorf("GetOrAdd err = %v, want %v", err, ErrCostTooLarge)
+	}
+	if err := c.AddOrReplace(k, large, 0); !errors.Is(err, ErrCostTooLarge) {
+		t.Errorf("AddOrReplace err = %v, want %v", err, ErrCostTooLarge)
+	}
+	if _, err := c.Compute(k, func(old string, exists bool) (string, bool) {
+		return large, true
+	}); !errors.Is(err, ErrCostTooLarge) {
+		t.Errorf("Compute err = %v, want %v", err, ErrCostTooLarge)
+	}
+	if swapped, err := c.CompareAndSwap(k, v, large); swapped || !errors.Is(err, ErrCostTooLarge) {
+		t.Errorf("CompareAndSwap = (%v, %v), want (false, %v)", swapped, err, ErrCostTooLarge)
+	}
+	if val, _ := c.Peek(k); val != v {
+		t.Errorf("val = %v, want %v", val, v)
+	}
+}
+
+func TestCache_CompareAndSwapIncomparable(t *testing.T) {
+	c, err := NewTyped[string, []int](5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Add(k, []int{1}, 0)
+	defer func() {
+		if recover() == nil {
+			t.Error("CompareAndSwap does not panic with incomparable values")
+		}
+	}()
+	_, _ = c.CompareAndSwap(k, []int{1}, []int{2})
+}
+
+// TestCache_ComputeConcurrent increments counters from many goroutines. It
+// should be run with the race detector.
+func TestCache_ComputeConcurrent(t *testing.T) {
+	c, err := NewTyped[int, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const goroutines, increments = 8, 500
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				key := j % 4
+				if j%2 == 0 {
+					_, _ = c.Compute(key, func(old int, exists bool) (int, bool) {
+						return old + 1, true
+					})
+					continue
+				}
+				for {
+					old, _, _ := c.GetOrAdd(key, 0, 0)
+					if swapped, _ := c.CompareAndSwap(key, old, old+1); swapped {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for key := 0; key < 4; key++ {
+		val, _ := c.Peek(key)
+		total += val
+	}
+	if total != goroutines*increments {
+		t.Errorf("total = %d, want %d", total, goroutines*increments)
+	}
+}
+
+// TestCache_CompareAndDeleteConcurrent deletes the same data from many
+// goroutines. Only one of them should succeed.
+func TestCache_CompareAndDeleteConcurrent(t *testing.T) {
+	c, err := NewTyped[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for round := 0; round < 100; round++ {
+		_ = c.AddOrReplace(k, round, 0)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		deleted := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if c.CompareAndDelete(k, round) {
+					mu.Lock()
+					deleted++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if deleted != 1 {
+			t.Fatalf("round %d: deleted %d times, want 1", round, deleted)
+		}
+	}
+}
+
+func TestShardedCache_Compute(t *testing.T) {
+	s, err := NewTypedSharded[int, int](4, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 800; j++ {
+				_, _ = s.Compute(j%8, func(old int, exists bool) (int, bool) {
+					return old + 1, true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	for key := 0; key < 8; key++ {
+		if val, _ := s.Peek(key); val != 800 {
+			t.Errorf("key %d = %d, want 800", key, val)
+		}
+	}
+
+	if actual, loaded, _ := s.GetOrAdd(100, 1, 0); actual != 1 || loaded {
+		t.Errorf("GetOrAdd = (%v, %v), want (1, false)", actual, loaded)
+	}
+	if swapped, _ := s.CompareAndSwap(100, 1, 2); !swapped {
+		t.Error("CompareAndSwap is not swapped")
+	}
+	if !s.CompareAndDelete(100, 2) {
+		t.Error("CompareAndDelete is not deleted")
+	}
+	if err := s.AddOrReplace(100, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := s.Get(100); val != 3 {
+		t.Errorf("val = %d, want 3", val)
+	}
+}
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_ConcurrentReaders(t *testing.T) {
+	c := createCache(t, 3)
+	addItemsWithExp(t, c, [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, time.Hour}})
+
+	// Holding the read lock must not block the readers that do not change the
+	// access order.
+	c.mu.RLock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Peek(k)
+		c.Contains(k + k)
+		c.Keys()
+		c.Range(func(key, val any, exp time.Time) bool { return true })
+		c.Items()
+		c.ActiveLen()
+		c.Stats()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("readers are blocked by another reader")
+	}
+	c.mu.RUnlock()
+	<-done
+}
+
+func TestCache_ConcurrentMixedWorkload(t *testing.T) {
+	const (
+		goroutines = 16
+		ops        = 2000
+		keys       = 64
+	)
+	c := createCache(t, keys/2)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprint((g*ops + i) % keys)
+				switch i % 8 {
+				case 0:
+					exp := time.Duration(0)
+					if i%3 == 0 {
+						exp = time.Microsecond
+					}
+					_ = c.Add(key, i, exp)
+				case 1:
+					c.Get(key)
+				case 2, 3:
+					c.Peek(key)
+				case 4:
+					c.Contains(key)
+				case 5:
+					c.Keys()
+				case 6:
+					_ = c.Remove(key)
+				case 7:
+					c.ClearExpiredData()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	checkInvariants(t, c)
+}
+
+// checkInvariants is a helper function that checks that the length, the list
+// and the index of the cache are consistent, the length and the free entries
+// do not exceed the capacity, every key is stored once, and the expiry queue
+// holds exactly the data with expiration.
+func checkInvariants(t *testing.T, c *Cache) {
+	t.Helper()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Len() != c.lst.len || c.Len() != len(c.items) {
+		t.Errorf("inconsistent length, len %v, list %v, index %v", c.Len(), c.lst.len, len(c.items))
+	}
+	if c.Len() > c.Cap() {
+		t.Errorf("length %v exceeds capacity %v", c.Len(), c.Cap())
+	}
+	if c.lst.len+c.lst.nfree > c.Cap() {
+		t.Errorf("list keeps %v entries, capacity is %v", c.lst.len+c.lst.nfree, c.Cap())
+	}
+	seen := make(map[any]bool)
+	expiring := 0
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		item := e.item
+		key := item.Key
+		if seen[key] {
+			t.Errorf("key %v is stored more than once", key)
+		}
+		seen[key] = true
+		if c.items[key] != e {
+			t.Errorf("index of key %v does not point to its list element", key)
+		}
+		if item.Expiration.IsZero() {
+			continue
+		}
+		expiring++
+		if e.index < 0 || e.index >= len(c.expiries) || c.expiries[e.index].entry != e ||
+			!c.expiries[e.index].expiration.Equal(item.Expiration) {
+			t.Errorf("key %v is not in the expiry queue with its expiration", key)
+		}
+	}
+	if expiring != len(c.expiries) {
+		t.Errorf("expiry queue has %v entries, want %v", len(c.expiries), expiring)
+	}
+}
+
+func TestCache_ConcurrentAddSameKey(t *testing.T) {
+	const goroutines = 64
+	c := createCache(t, goroutines)
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		added int
+	)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			if err := c.Add(k, g, 0); err == nil {
+				mu.Lock()
+				added++
+				mu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+	if added != 1 {
+		t.Errorf("expected exactly one successful Add, got %v", added)
+	}
+	if c.Len() != 1 {
+		t.Errorf("unexpected length, got %v, want %v", c.Len(), 1)
+	}
+	checkInvariants(t, c)
+}
+
+func TestCache_ConcurrentAllMethods(t *testing.T) {
+	const (
+		goroutines = 16
+		ops        = 1000
+		keys       = 32
+		minCap     = 8
+		maxCap     = 24
+	)
+	c := createCache(t, maxCap)
+	var evictions [ReasonReplaced + 1]atomic.Uint64
+	c.OnEvict(func(key, val any, reason EvictReason) {
+		evictions[reason].Add(1)
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := (g*ops + i) % keys
+				switch i % 17 {
+				case 0:
+					_ = c.Add(key, i, 0)
+				case 1:
+					_ = c.Add(key, i, time.Microsecond)
+				case 2:
+					c.Get(key)
+				case 3:
+					_ = c.Remove(key)
+				case 4:
+					c.Contains(key)
+				case 5:
+					if i%100 == 5 {
+						c.Clear()
+					}
+				case 6:
+					if got := c.Keys(); len(got) > maxCap {
+						t.Errorf("Keys() returned %v keys, capacity is at most %v", len(got), maxCap)
+					}
+					c.Range(func(key, val any, exp time.Time) bool { return true })
+					c.Items()
+				case 7:
+					c.Peek(key)
+				case 8:
+					c.RemoveOldest()
+				case 9:
+					c.Resize(minCap + i%(maxCap-minCap+1))
+				case 10:
+					if l := c.Len(); l > maxCap {
+						t.Errorf("Len() = %v exceeds maximum capacity %v", l, maxCap)
+					}
+				case 11:
+					c.ActiveLen()
+				case 12:
+					if cp := c.Cap(); cp < minCap || cp > maxCap {
+						t.Errorf("Cap() = %v is out of [%v, %v]", cp, minCap, maxCap)
+					}
+				case 13:
+					_ = c.Replace(key, i)
+				case 14:
+					c.ClearExpiredData()
+				case 15:
+					_, _ = c.UpdateVal(key, i)
+				case 16:
+					_, _ = c.UpdateExpirationDate(key, time.Hour)
+					c.Stats()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	checkInvariants(t, c)
+
+	// Every removal counted in the statistics is reported to the callback
+	// with the same reason.
+	stats := c.Stats()
+	for r := ReasonCapacity; r <= ReasonReplaced; r++ {
+		if got, want := evictions[r].Load(), stats.Evictions[r]; got != want {
+			t.Errorf("OnEvict is called %v times for %v, want %v", got, r, want)
+		}
+	}
+
+	keySet := make(map[any]bool)
+	for _, key := range c.Keys() {
+		if keySet[key] {
+			t.Errorf("Keys() returned key %v more than once", key)
+		}
+		keySet[key] = true
+	}
+}
@@ -0,0 +1,86 @@
+package cache
+
+import "fmt"
+
+// SetWeigher registers fn to compute the cost of the data, which is limited by
+// WithMaxCost. fn must return a non-negative cost; negative costs are treated
+// as zero. The costs of the cached data are recomputed and the victims of the
+// eviction policy are evicted until the total cost fits. Passing nil makes
+// every data cost one.
+func (c *TypedCache[K, V]) SetWeigher(fn func(key K, val V) int64) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.weigher = fn
+	var total int64
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		e.item.cost = c.weigh(e.item.Key, e.item.Val)
+		total += e.item.cost
+	}
+	c.cost.Store(total)
+	c.evictOverCost(ReasonCapacity)
+}
+
+// Cost returns the total cost of the cached data.
+func (c *TypedCache[K, V]) Cost() int64 {
+	return c.cost.Load()
+}
+
+// MaxCost returns the maximum total cost of the cache. Zero means that there
+// is no cost limit.
+func (c *TypedCache[K, V]) MaxCost() int64 {
+	return c.maxCost.Load()
+}
+
+// ResizeCost changes the maximum total cost. If the total cost exceeds the new
+// maximum cost, the victims of the eviction policy are removed until it fits.
+// It returns the number of the removed data. Zero removes the cost limit, and
+// a negative maximum cost returns ErrInvalidConfig like WithMaxCost without
+// changing the limit.
+func (c *TypedCache[K, V]) ResizeCost(maxCost int64) (int, error) {
+	if maxCost < 0 {
+		return 0, fmt.Errorf("%w: maximum cost %d is negative", ErrInvalidConfig, maxCost)
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.maxCost.Store(maxCost)
+	return c.evictOverCost(ReasonResized), nil
+}
+
+// weigh returns the cost of the given data.
+func (c *TypedCache[K, V]) weigh(key K, val V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	if cost := c.weigher(key, val); cost > 0 {
+		return cost
+	}
+	return 0
+}
+
+// checkCost returns error if the cost of a single data exceeds the maximum
+// cost, since no eviction can make room for it.
+func (c *TypedCache[K, V]) checkCost(key K, cost int64) error {
+	if maxCost := c.MaxCost(); maxCost > 0 && cost > maxCost {
+		return keyError(key, ErrCostTooLarge)
+	}
+	return nil
+}
+
+// overCost reports whether adding data with the given cost exceeds the
+// maximum cost.
+func (c *TypedCache[K, V]) overCost(cost int64) bool {
+	maxCost := c.MaxCost()
+	return maxCost > 0 && c.Cost()+cost > maxCost
+}
+
+// evictOverCost evicts the victims of the eviction policy with the given reason
+// until the total cost fits the maximum cost. It returns the number of the
+// evicted data.
+func (c *TypedCache[K, V]) evictOverCost(reason EvictReason) int {
+	var n int
+	for c.Len() > 0 && c.overCost(0) {
+		c.removeElement(c.victim(), reason)
+		n++
+	}
+	return n
+}
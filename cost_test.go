@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// createCostCache creates a cache whose data costs the length of the value.
+// It is a helper function to prevent code duplication.
+func createCostCache(t *testing.T, cap int, maxCost int64) *TypedCache[string, string] {
+	t.Helper()
+	c, err := NewTyped[string, string](cap, WithMaxCost(maxCost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetWeigher(func(key, val string) int64 {
+		return int64(len(val))
+	})
+	return c
+}
+
+// sortedKeys returns the keys of the cache in sorted order.
+func sortedKeys(c *TypedCache[string, string]) []string {
+	keys := c.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+func TestCache_MaxCost(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxCost     int64
+		action      func(c *TypedCache[string, string]) error
+		wantErr     error
+		wantKeys    []string
+		wantCost    int64
+		wantEvicted map[string]EvictReason
+	}{
+		{
+			name:    "adds data while total cost fits",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "12345", 0)
+				return c.Add("b", "1234", 0)
+			},
+			wantKeys:    []string{"a", "b"},
+			wantCost:    9,
+			wantEvicted: map[string]EvictReason{},
+		},
+		{
+			name:    "evicts LRU data until new data fits",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "12345", 0)
+				_ = c.Add("b", "1234", 0)
+				_ = c.Add("c", "1", 0)
+				return c.Add("d", "123456", 0)
+			},
+			wantKeys:    []string{"c", "d"},
+			wantCost:    7,
+			wantEvicted: map[string]EvictReason{"a": ReasonCapacity, "b": ReasonCapacity},
+		},
+		{
+			name:    "returns error when a single data exceeds max cost",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "1", 0)
+				return c.Add("b", "12345678901", 0)
+			},
+			wantErr:     ErrCostTooLarge,
+			wantKeys:    []string{"a"},
+			wantCost:    1,
+			wantEvicted: map[string]EvictReason{},
+		},
+		{
+			name:    "UpdateVal evicts LRU data when new value does not fit",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "123", 0)
+				_ = c.Add("b", "123", 0)
+				_ = c.Add("c", "123", 0)
+				_, err := c.UpdateVal("c", "12345")
+				return err
+			},
+			wantKeys:    []string{"b", "c"},
+			wantCost:    8,
+			wantEvicted: map[string]EvictReason{"a": ReasonCapacity, "c": ReasonReplaced},
+		},
+		{
+			name:    "Replace evicts LRU data when new value does not fit",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "123", 0)
+				_ = c.Add("b", "123", 0)
+				_ = c.Add("c", "123", 0)
+				return c.Replace("c", "1234567")
+			},
+			wantKeys:    []string{"b", "c"},
+			wantCost:    10,
+			wantEvicted: map[string]EvictReason{"a": ReasonCapacity, "c": ReasonReplaced},
+		},
+		{
+			name:    "Replace returns error when new value exceeds max cost",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "123", 0)
+				return c.Replace("a", "12345678901")
+			},
+			wantErr:     ErrCostTooLarge,
+			wantKeys:    []string{"a"},
+			wantCost:    3,
+			wantEvicted: map[string]EvictReason{},
+		},
+		{
+			name:    "ResizeCost prunes LRU data",
+			maxCost: 10,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "123", 0)
+				_ = c.Add("b", "123", 0)
+				_ = c.Add("c", "123", 0)
+				if n, err := c.ResizeCost(4); err != nil || n != 2 {
+					t.Errorf("unexpected number of pruned data, got %v, want %v", n, 2)
+				}
+				return nil
+			},
+			wantKeys:    []string{"c"},
+			wantCost:    3,
+			wantEvicted: map[string]EvictReason{"a": ReasonResized, "b": ReasonResized},
+		},
+		{
+			name:    "Resize keeps capacity limit together with cost limit",
+			maxCost: 100,
+			action: func(c *TypedCache[string, string]) error {
+				_ = c.Add("a", "123", 0)
+				_ = c.Add("b", "123", 0)
+				c.Resize(1)
+				return nil
+			},
+			wantKeys:    []string{"b"},
+			wantCost:    3,
+			wantEvicted: map[string]EvictReason{"a": ReasonResized},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCostCache(t, 10, tt.maxCost)
+			evicted := make(map[string]EvictReason)
+			c.OnEvict(func(key, val string, reason EvictReason) {
+				evicted[key] = reason
+			})
+			if err := tt.action(c); !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error, got %v, want %v", err, tt.wantErr)
+			}
+			if got := sortedKeys(c); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("unexpected keys, got %v, want %v", got, tt.wantKeys)
+			}
+			if got := c.Cost(); got != tt.wantCost {
+				t.Errorf("unexpected cost, got %v, want %v", got, tt.wantCost)
+			}
+			if !reflect.DeepEqual(evicted, tt.wantEvicted) {
+				t.Errorf("unexpected evictions, got %v, want %v", evicted, tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestCache_SetWeigher(t *testing.T) {
+	c, err := NewTyped[string, string](10, WithMaxCost(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without weigher, every data costs one.
+	for _, key := range []string{"a", "b", "c"} {
+		_ = c.Add(key, key+key+key, 0)
+	}
+	if c.Cost() != 3 || c.MaxCost() != 5 {
+		t.Errorf("unexpected cost, got %v/%v, want %v/%v", c.Cost(), c.MaxCost(), 3, 5)
+	}
+
+	c.SetWeigher(func(key, val string) int64 {
+		return int64(len(val))
+	})
+	if got, want := sortedKeys(c), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected keys after setting weigher, got %v, want %v", got, want)
+	}
+	if c.Cost() != 3 {
+		t.Errorf("unexpected cost, got %v, want %v", c.Cost(), 3)
+	}
+
+	c.SetWeigher(nil)
+	if c.Cost() != 1 {
+		t.Errorf("unexpected cost after removing weigher, got %v, want %v", c.Cost(), 1)
+	}
+	if _, err := c.ResizeCost(0); err != nil || c.MaxCost() != 0 {
+		t.Errorf("unexpected max cost, got %v, want %v", c.MaxCost(), 0)
+	}
+}
+
+func TestShardedCache_MaxCost(t *testing.T) {
+	s, err := NewTypedSharded[int, string](3, 30, WithMaxCost(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCosts := []int64{4, 3, 3}
+	for i, c := range s.shards {
+		if c.MaxCost() != wantCosts[i] {
+			t.Errorf("unexpected max cost of shard %d, got %v, want %v", i, c.MaxCost(), wantCosts[i])
+		}
+	}
+	for i := 0; i < 30; i++ {
+		_ = s.Add(i, "x", 0)
+	}
+	if s.Cost() > s.MaxCost() || s.MaxCost() != 10 {
+		t.Errorf("unexpected cost, got %v/%v, want at most %v", s.Cost(), s.MaxCost(), 10)
+	}
+}
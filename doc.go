@@ -1,8 +1,9 @@
 /*
 Package cache is a cache package written in Go with no dependency. It uses
 Least-Recently Used (LRU) algorithm for replacement policy. Behind the package,
-it uses doubly-linked list which is built-in data structure that comes from
-container/list. Any data can be stored in cache.
+it uses a doubly-linked list whose nodes hold the data directly and are reused
+after the data is removed, so reading and overwriting the data do not
+allocate. Any data can be stored in cache.
 
 Firstly, you need to create a new cache as follows.
 
@@ -11,5 +12,20 @@ Firstly, you need to create a new cache as follows.
 It takes one parameter that is capacity of the cache. For the example, the
 cache can keep up to 5 data. If a new data is being tried to add when the cache
 is full, the gcache removes the least-recently used one and adds the new data.
+Other eviction policies can be selected with the WithPolicy option.
+
+	c, err := cache.New(5, cache.WithPolicy(cache.PolicyARC))
+
+Cache accepts any comparable data as key and any data as value. If the key and
+value types are known, NewTyped creates a TypedCache which checks the types at
+compile time and returns zero values instead of nil for missing data.
+
+	c, err := cache.NewTyped[string, int](5)
+
+For millions of small byte slices, NewBytesCache creates a BytesCache which
+stores the data in large byte buffers that the garbage collector does not
+scan. Its capacity is given in bytes.
+
+	c, err := cache.NewBytesCache(256 << 20)
 */
 package cache
@@ -1,12 +1,79 @@
 package cache
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	errEmptyCache   = errors.New("cache is empty")
-	errNegCapacity  = errors.New("capacity cannot be negative")
-	errZeroCapacity = errors.New("cache capacity should be more than zero")
-	errKeyExist     = errors.New("key already exists")
-	errKeyNotExist  = errors.New("key does not exist")
-	errNoKey        = errors.New("there is no such key")
+	// ErrEmptyCache is returned when data is removed from an empty cache.
+	ErrEmptyCache = errors.New("cache is empty")
+
+	// ErrNegativeCapacity is returned when the capacity is negative.
+	ErrNegativeCapacity = errors.New("capacity cannot be negative")
+
+	// ErrZeroCapacity is returned when the capacity is zero.
+	ErrZeroCapacity = errors.New("cache capacity should be more than zero")
+
+	// ErrKeyExists is returned when the added key already exists.
+	ErrKeyExists = errors.New("key already exists")
+
+	// ErrKeyNotFound is returned when the given key does not exist.
+	ErrKeyNotFound = errors.New("key does not exist")
+
+	// ErrCostTooLarge is returned when the cost of a data exceeds the
+	// maximum cost of the cache.
+	ErrCostTooLarge = errors.New("cost exceeds maximum cost")
+
+	// ErrShardCount is returned when the shard count is not positive.
+	ErrShardCount = errors.New("shard count should be more than zero")
+
+	// ErrShardCapacity is returned when the capacity is less than the shard
+	// count.
+	ErrShardCapacity = errors.New("capacity cannot be less than shard count")
+
+	// ErrLoaderPanic is returned to the callers waiting for a load when the
+	// loader panics.
+	ErrLoaderPanic = errors.New("loader panicked")
+
+	// ErrInvalidSnapshot is returned when a snapshot has an unknown version
+	// or an invalid header.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+	// ErrCorruptLog is returned when a write-ahead log record is intact but
+	// it cannot be applied.
+	ErrCorruptLog = errors.New("corrupt write-ahead log")
+
+	// ErrInvalidConfig is returned when an option has an invalid value, such
+	// as an unknown eviction policy or a negative duration.
+	ErrInvalidConfig = errors.New("invalid configuration")
+
+	// ErrEntryTooLarge is returned when a data does not fit in a segment of
+	// a BytesCache.
+	ErrEntryTooLarge = errors.New("entry exceeds segment size")
 )
+
+// KeyError records an error and the key that caused it. Err is one of the
+// sentinel errors, so KeyError can be matched with errors.Is.
+type KeyError struct {
+	// Key is the key that caused the error.
+	Key any
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the error message with the key.
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key %v: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// keyError wraps err with the given key.
+func keyError[K comparable](key K, err error) error {
+	return &KeyError{Key: key, Err: err}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(c *Cache) error
+		wantErr error
+		wantKey any
+	}{
+		{
+			name:    "Add returns ErrKeyExists with key",
+			call:    func(c *Cache) error { return c.Add(k, v, 0) },
+			wantErr: ErrKeyExists,
+			wantKey: k,
+		},
+		{
+			name:    "Replace returns ErrKeyNotFound with key",
+			call:    func(c *Cache) error { return c.Replace("nonexistent", v) },
+			wantErr: ErrKeyNotFound,
+			wantKey: "nonexistent",
+		},
+		{
+			name: "UpdateVal returns ErrKeyNotFound with key",
+			call: func(c *Cache) error {
+				_, err := c.UpdateVal("nonexistent", v)
+				return err
+			},
+			wantErr: ErrKeyNotFound,
+			wantKey: "nonexistent",
+		},
+		{
+			name: "UpdateExpirationDate returns ErrKeyNotFound with key",
+			call: func(c *Cache) error {
+				_, err := c.UpdateExpirationDate("nonexistent", time.Hour)
+				return err
+			},
+			wantErr: ErrKeyNotFound,
+			wantKey: "nonexistent",
+		},
+	}
+	for _, tt := range tests {
+		c := createCache(t, 2)
+		addItems(t, c, [][]any{{k, v}})
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error, got %v, want %v", err, tt.wantErr)
+			}
+			var keyErr *KeyError
+			if !errors.As(err, &keyErr) {
+				t.Fatalf("expected error to be *KeyError, got %T", err)
+			}
+			if keyErr.Key != tt.wantKey {
+				t.Errorf("unexpected error key, got %v, want %v", keyErr.Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestKeyError_Error(t *testing.T) {
+	err := &KeyError{Key: k, Err: ErrKeyExists}
+	if got, want := err.Error(), "key foo: key already exists"; got != want {
+		t.Errorf("KeyError.Error() = %q, want %q", got, want)
+	}
+}
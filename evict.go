@@ -0,0 +1,85 @@
+package cache
+
+// EvictReason is the reason of a data removal from the cache.
+type EvictReason int
+
+const (
+	// ReasonCapacity means that the data is evicted to make room for a new
+	// data when the cache is full.
+	ReasonCapacity EvictReason = iota + 1
+
+	// ReasonExpired means that the data is removed since it is expired.
+	ReasonExpired
+
+	// ReasonRemoved means that the data is removed explicitly by Remove or
+	// RemoveOldest.
+	ReasonRemoved
+
+	// ReasonCleared means that the data is removed by Clear.
+	ReasonCleared
+
+	// ReasonResized means that the data is pruned by Resize.
+	ReasonResized
+
+	// ReasonReplaced means that the value is overwritten by Replace or
+	// UpdateVal. The old value is reported.
+	ReasonReplaced
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonRemoved:
+		return "removed"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonResized:
+		return "resized"
+	case ReasonReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// evicted is a removed data waiting for the eviction callback.
+type evicted[K comparable, V any] struct {
+	item   TypedItem[K, V]
+	reason EvictReason
+}
+
+// OnEvict registers fn to be called whenever a data is removed from the cache
+// or its value is overwritten. fn receives the key, the removed value and the
+// reason of the removal. It is called after the cache lock is released, so it
+// may call the cache methods. Passing nil unregisters the callback.
+func (c *TypedCache[K, V]) OnEvict(fn func(key K, val V, reason EvictReason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// notify counts the removal in statistics and records the removed item for the
+// eviction callback, if there is a registered one.
+func (c *TypedCache[K, V]) notify(item TypedItem[K, V], reason EvictReason) {
+	c.stats.evictions[reason].Add(1)
+	if c.onEvict == nil {
+		return
+	}
+	c.evicted = append(c.evicted, evicted[K, V]{item: item, reason: reason})
+}
+
+// unlock releases the cache lock and then calls the eviction callback for the
+// items removed while the lock was held.
+func (c *TypedCache[K, V]) unlock() {
+	fn, pending := c.onEvict, c.evicted
+	c.evicted = nil
+	c.commit()
+	c.mu.Unlock()
+	for _, e := range pending {
+		fn(e.item.Key, e.item.Val, e.reason)
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// evictRecord is a single call of the eviction callback.
+type evictRecord struct {
+	key    any
+	val    any
+	reason EvictReason
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		addPairs [][]any
+		action   func(c *Cache)
+		want     []evictRecord
+	}{
+		{
+			name:     "reports LRU data evicted by capacity",
+			capacity: 2,
+			addPairs: [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				_ = c.Add(k+k+k, v+v+v, 0)
+			},
+			want: []evictRecord{{k, v, ReasonCapacity}},
+		},
+		{
+			name:     "reports expired data cleared by ClearExpiredData",
+			capacity: 3,
+			addPairs: [][]any{{k, v, -1 * time.Hour}, {k + k, v + v, time.Hour}},
+			action: func(c *Cache) {
+				c.ClearExpiredData()
+			},
+			want: []evictRecord{{k, v, ReasonExpired}},
+		},
+		{
+			name:     "reports expired data removed on access",
+			capacity: 3,
+			addPairs: [][]any{{k, v, -1 * time.Hour}},
+			action: func(c *Cache) {
+				c.Get(k)
+			},
+			want: []evictRecord{{k, v, ReasonExpired}},
+		},
+		{
+			name:     "reports data removed by Remove and RemoveOldest",
+			capacity: 3,
+			addPairs: [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				_ = c.Remove(k + k)
+				c.RemoveOldest()
+			},
+			want: []evictRecord{{k + k, v + v, ReasonRemoved}, {k, v, ReasonRemoved}},
+		},
+		{
+			name:     "reports data removed by Clear",
+			capacity: 3,
+			addPairs: [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				c.Clear()
+			},
+			want: []evictRecord{{k + k, v + v, ReasonCleared}, {k, v, ReasonCleared}},
+		},
+		{
+			name:     "reports data pruned by Resize",
+			capacity: 3,
+			addPairs: [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, 0 * time.Hour}, {k + k + k, v + v + v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				c.Resize(1)
+			},
+			want: []evictRecord{{k, v, ReasonResized}, {k + k, v + v, ReasonResized}},
+		},
+		{
+			name:     "reports old values overwritten by Replace and UpdateVal",
+			capacity: 3,
+			addPairs: [][]any{{k, v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				_ = c.Replace(k, v+v)
+				_, _ = c.UpdateVal(k, v+v+v)
+			},
+			want: []evictRecord{{k, v, ReasonReplaced}, {k, v + v, ReasonReplaced}},
+		},
+		{
+			name:     "reports nothing when expiration date is updated",
+			capacity: 3,
+			addPairs: [][]any{{k, v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				_, _ = c.UpdateExpirationDate(k, time.Hour)
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		c := createCache(t, tt.capacity)
+		addItemsWithExp(t, c, tt.addPairs)
+		t.Run(tt.name, func(t *testing.T) {
+			var got []evictRecord
+			c.OnEvict(func(key, val any, reason EvictReason) {
+				got = append(got, evictRecord{key, val, reason})
+			})
+			tt.action(c)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected evictions, got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCache_OnEvictCallsCache(t *testing.T) {
+	c := createCache(t, 1)
+	var got []any
+	c.OnEvict(func(key, val any, reason EvictReason) {
+		// The callback is called without holding the lock, so it can use the
+		// cache without a deadlock.
+		got = append(got, c.Keys()...)
+	})
+	addItems(t, c, [][]any{{k, v}, {k + k, v + v}})
+	if want := []any{k + k}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected keys in callback, got %v, want %v", got, want)
+	}
+
+	c.OnEvict(nil)
+	_ = c.Remove(k + k)
+	if len(got) != 1 {
+		t.Errorf("expected unregistered callback not to be called, got %v calls", len(got))
+	}
+}
+
+func TestEvictReason_String(t *testing.T) {
+	tests := []struct {
+		reason EvictReason
+		want   string
+	}{
+		{ReasonCapacity, "capacity"},
+		{ReasonExpired, "expired"},
+		{ReasonRemoved, "removed"},
+		{ReasonCleared, "cleared"},
+		{ReasonResized, "resized"},
+		{ReasonReplaced, "replaced"},
+		{EvictReason(0), "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.reason.String(); got != tt.want {
+			t.Errorf("EvictReason(%d).String() = %v, want %v", tt.reason, got, tt.want)
+		}
+	}
+}
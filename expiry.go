@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// NoExpiration is passed as the expiration duration to add data that
+	// never expires, even if the cache has a default TTL.
+	NoExpiration time.Duration = math.MaxInt64
+
+	// KeepTTL is passed as the expiration duration to keep the expiration of
+	// the existing data. It is used by AddOrReplace and UpdateExpirationDate.
+	// For new data, it means the default TTL.
+	KeepTTL time.Duration = math.MinInt64
+)
+
+// newItem creates an item which expires after exp. Zero exp means the default
+// TTL of the cache, which is no expiration unless WithDefaultTTL is given.
+// The expiration is spread by the TTL jitter.
+func (c *TypedCache[K, V]) newItem(key K, val V, exp time.Duration) TypedItem[K, V] {
+	item := TypedItem[K, V]{
+		Key: key,
+		Val: val,
+	}
+	item.Expiration, item.ttl = c.expiration(exp, c.clock.Now())
+	return item
+}
+
+// expiration returns the expiration time of the data added at now with the
+// expiration duration exp, and the TTL that the data is added with. The zero
+// time is returned if the data never expires.
+func (c *TypedCache[K, V]) expiration(exp time.Duration, now time.Time) (time.Time, time.Duration) {
+	switch exp {
+	case NoExpiration:
+		return time.Time{}, 0
+	case 0, KeepTTL:
+		exp = c.defaultTTL
+	}
+	if exp == 0 {
+		return time.Time{}, 0
+	}
+	return now.Add(jitter(exp, c.ttlJitter)), exp
+}
+
+// jitter returns the TTL changed randomly by up to the given fraction.
+func jitter(ttl time.Duration, fraction float64) time.Duration {
+	if fraction == 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*fraction*float64(ttl))
+}
+
+// slide pushes the expiration of the data in e forward by its TTL if the
+// cache has sliding expiration. The cache mutex must be held.
+func (c *TypedCache[K, V]) slide(e *entry[K, V], now time.Time) {
+	if !c.sliding || e.item.ttl <= 0 {
+		return
+	}
+	e.item.Expiration = now.Add(jitter(e.item.ttl, c.ttlJitter))
+	c.schedule(e)
+	c.logSet(e.item)
+}
+
+// expiryNode is an element of the expiry queue. The expiration is kept in the
+// queue, so comparing the nodes does not dereference the entries.
+type expiryNode[K comparable, V any] struct {
+	expiration time.Time
+	entry      *entry[K, V]
+}
+
+// expiryQueue is a min-heap of the entries with expiration ordered by their
+// expiration time, so the expired data can be found without scanning the
+// whole cache. It does not use container/heap, since boxing the nodes in
+// interfaces allocates on every removal.
+type expiryQueue[K comparable, V any] []expiryNode[K, V]
+
+// push adds the node to the queue.
+func (q *expiryQueue[K, V]) push(node expiryNode[K, V]) {
+	node.entry.index = len(*q)
+	*q = append(*q, node)
+	q.up(len(*q) - 1)
+}
+
+// remove removes the node at index i from the queue.
+func (q *expiryQueue[K, V]) remove(i int) {
+	n := len(*q) - 1
+	if i != n {
+		q.swap(i, n)
+		if !q.down(i, n) {
+			q.up(i)
+		}
+	}
+	(*q)[n].entry.index = -1
+	(*q)[n] = expiryNode[K, V]{}
+	*q = (*q)[:n]
+}
+
+// fix restores the heap order after the expiration at index i is changed.
+func (q expiryQueue[K, V]) fix(i int) {
+	if !q.down(i, len(q)) {
+		q.up(i)
+	}
+}
+
+func (q expiryQueue[K, V]) swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].entry.index = i
+	q[j].entry.index = j
+}
+
+func (q expiryQueue[K, V]) up(j int) {
+	for {
+		i := (j - 1) / 2
+		if i == j || !q[j].expiration.Before(q[i].expiration) {
+			break
+		}
+		q.swap(i, j)
+		j = i
+	}
+}
+
+func (q expiryQueue[K, V]) down(i0, n int) bool {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 {
+			break
+		}
+		j := j1
+		if j2 := j1 + 1; j2 < n && q[j2].expiration.Before(q[j1].expiration) {
+			j = j2
+		}
+		if !q[j].expiration.Before(q[i].expiration) {
+			break
+		}
+		q.swap(i, j)
+		i = j
+	}
+	return i > i0
+}
+
+// countBefore returns the number of entries that expire before now. It only
+// visits the matching entries and their children.
+func (q expiryQueue[K, V]) countBefore(now time.Time) int {
+	var n int
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(q) || !q[i].expiration.Before(now) {
+			continue
+		}
+		n++
+		stack = append(stack, 2*i+1, 2*i+2)
+	}
+	return n
+}
+
+// schedule updates the expiry queue after the data in e is added or its
+// expiration is changed. The cache mutex must be held.
+func (c *TypedCache[K, V]) schedule(e *entry[K, V]) {
+	switch {
+	case e.item.Expiration.IsZero() && e.index < 0:
+	case e.item.Expiration.IsZero():
+		c.expiries.remove(e.index)
+	case e.index < 0:
+		c.expiries.push(expiryNode[K, V]{expiration: e.item.Expiration, entry: e})
+	case !c.expiries[e.index].expiration.Equal(e.item.Expiration):
+		c.expiries[e.index].expiration = e.item.Expiration
+		c.expiries.fix(e.index)
+	}
+}
+
+// unschedule removes e from the expiry queue. The cache mutex must be held.
+func (c *TypedCache[K, V]) unschedule(e *entry[K, V]) {
+	if e.index >= 0 {
+		c.expiries.remove(e.index)
+	}
+}
+
+// expiryCutoff returns the time before which the expired items can be
+// removed. The items in the stale-while-revalidate window are kept.
+func (c *TypedCache[K, V]) expiryCutoff(now time.Time) time.Time {
+	if r := &c.refresher; r.loader != nil && r.staleWindow > 0 {
+		return now.Add(-r.staleWindow)
+	}
+	return now
+}
@@ -0,0 +1,580 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+// expiresIn returns the duration until the data of the key expires. It is a
+// helper function to prevent code duplication.
+func expiresIn(t *testing.T, c *Cache, key any) time.Duration {
+	t.Helper()
+	item, found := findItem(t, c, key)
+	if !found {
+		t.Fatalf("%v is not found", key)
+	}
+	if item.Expiration.IsZero() {
+		return 0
+	}
+	return time.Until(item.Expiration)
+}
+
+func TestCache_DefaultTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     func(c *Cache)
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name: "Add with zero duration uses default TTL",
+			add: func(c *Cache) {
+				_ = c.Add(k, v, 0)
+			},
+			wantMin: 59 * time.Minute,
+			wantMax: time.Hour,
+		},
+		{
+			name: "Add with NoExpiration never expires",
+			add: func(c *Cache) {
+				_ = c.Add(k, v, NoExpiration)
+			},
+		},
+		{
+			name: "Add with duration overrides default TTL",
+			add: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+			},
+			wantMin: 59 * time.Second,
+			wantMax: time.Minute,
+		},
+		{
+			name: "AddOrReplace uses default TTL",
+			add: func(c *Cache) {
+				_ = c.AddOrReplace(k, v, 0)
+			},
+			wantMin: 59 * time.Minute,
+			wantMax: time.Hour,
+		},
+		{
+			name: "Compute uses default TTL",
+			add: func(c *Cache) {
+				_, _ = c.Compute(k, func(old any, exists bool) (any, bool) {
+					return v, true
+				})
+			},
+			wantMin: 59 * time.Minute,
+			wantMax: time.Hour,
+		},
+		{
+			name: "GetOrLoad uses default TTL",
+			add: func(c *Cache) {
+				_, _ = c.GetOrLoad(k, func() (any, time.Duration, error) {
+					return v, 0, nil
+				})
+			},
+			wantMin: 59 * time.Minute,
+			wantMax: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(5, WithDefaultTTL(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.add(c)
+			if got := expiresIn(t, c, k); got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("expires in %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestCache_TTLJitter(t *testing.T) {
+	const n = 1000
+	c, err := New(n, WithTTLJitter(0.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		_ = c.Add(i, i, time.Hour)
+	}
+
+	lo, hi := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < n; i++ {
+		d := expiresIn(t, c, i)
+		if d < lo {
+			lo = d
+		}
+		if d > hi {
+			hi = d
+		}
+	}
+	if lo < 53*time.Minute || hi > 66*time.Minute {
+		t.Errorf("expirations are between %v and %v, want within 10%% of an hour", lo, hi)
+	}
+	if hi-lo < 6*time.Minute {
+		t.Errorf("expirations are spread over %v, want them spread out", hi-lo)
+	}
+
+	_ = c.Add("forever", v, 0)
+	if got := expiresIn(t, c, "forever"); got != 0 {
+		t.Errorf("data without expiration expires in %v", got)
+	}
+}
+
+func TestCache_TTLJitterBounds(t *testing.T) {
+	for _, fraction := range []float64{-0.5, 1, 2} {
+		if _, err := New(5, WithTTLJitter(fraction)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("jitter of %v returns %v, want %v", fraction, err, ErrInvalidConfig)
+		}
+	}
+	c, err := New(5, WithTTLJitter(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ttlJitter != 0 {
+		t.Errorf("jitter of 0 = %v, want 0", c.ttlJitter)
+	}
+}
+
+func TestCache_SlidingExpiration(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := New(5, WithSlidingExpiration(), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Add(k, v, 100*time.Millisecond)
+	_ = c.Add(k+k, v+v, 100*time.Millisecond)
+	_ = c.Add(k+k+k, v+v+v, 0)
+
+	for i := 0; i < 4; i++ {
+		clock.Advance(40 * time.Millisecond)
+		if _, found := c.Get(k); !found {
+			t.Fatalf("read data expired after %d reads", i)
+		}
+		c.Peek(k + k)
+	}
+	if c.Contains(k + k) {
+		t.Error("data that is only peeked is not expired")
+	}
+	if ttl, _ := c.TTL(k + k + k); ttl != NoExpiration {
+		t.Errorf("data without expiration expires in %v", ttl)
+	}
+
+	clock.Advance(150 * time.Millisecond)
+	if _, found := c.Get(k); found {
+		t.Error("data is not expired after it is not read for the TTL")
+	}
+}
+
+func TestCache_SlidingExpirationGetOrAdd(t *testing.T) {
+	c, err := New(5, WithSlidingExpiration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Add(k, v, time.Minute)
+	item, _ := findItem(t, c, k)
+	item.Expiration = item.Expiration.Add(-30 * time.Second)
+	c.items[k].item = item
+
+	if _, loaded, _ := c.GetOrAdd(k, v+v, 0); !loaded {
+		t.Fatal("existing data is not loaded")
+	}
+	if got := expiresIn(t, c, k); got < 59*time.Second {
+		t.Errorf("expires in %v, want the expiration pushed forward", got)
+	}
+}
+
+func TestCache_ExpiryQueue(t *testing.T) {
+	tests := []struct {
+		name           string
+		action         func(c *Cache)
+		wantActiveLen  int
+		wantQueueLen   int
+		wantAfterClear []any
+	}{
+		{
+			name: "tracks only data with expiration",
+			action: func(c *Cache) {
+				_ = c.Add("a", 1, 0)
+				_ = c.Add("b", 2, time.Hour)
+				_ = c.Add("c", 3, -time.Hour)
+			},
+			wantActiveLen:  2,
+			wantQueueLen:   2,
+			wantAfterClear: []any{"b", "a"},
+		},
+		{
+			name: "follows expiration changes",
+			action: func(c *Cache) {
+				_ = c.Add("a", 1, time.Hour)
+				_ = c.Add("b", 2, time.Hour)
+				c.items["a"].item = withExpiration(c.items["a"].item, -time.Hour)
+				c.schedule(c.items["a"])
+			},
+			wantActiveLen:  1,
+			wantQueueLen:   2,
+			wantAfterClear: []any{"b"},
+		},
+		{
+			name: "drops removed data",
+			action: func(c *Cache) {
+				_ = c.Add("a", 1, -time.Hour)
+				_ = c.Add("b", 2, -time.Hour)
+				_ = c.Remove("a")
+				_ = c.Replace("b", 3)
+			},
+			wantActiveLen:  0,
+			wantQueueLen:   0,
+			wantAfterClear: []any{},
+		},
+		{
+			name: "drops replaced expiration",
+			action: func(c *Cache) {
+				_ = c.Add("a", 1, -time.Hour)
+				_ = c.AddOrReplace("a", 2, NoExpiration)
+			},
+			wantActiveLen:  1,
+			wantQueueLen:   0,
+			wantAfterClear: []any{"a"},
+		},
+		{
+			name: "is emptied by Clear",
+			action: func(c *Cache) {
+				_ = c.Add("a", 1, -time.Hour)
+				c.Clear()
+				_ = c.Add("b", 2, time.Hour)
+			},
+			wantActiveLen:  1,
+			wantQueueLen:   1,
+			wantAfterClear: []any{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			tt.action(c)
+			if got := c.ActiveLen(); got != tt.wantActiveLen {
+				t.Errorf("ActiveLen = %d, want %d", got, tt.wantActiveLen)
+			}
+			if got := len(c.expiries); got != tt.wantQueueLen {
+				t.Errorf("queue length = %d, want %d", got, tt.wantQueueLen)
+			}
+			checkInvariants(t, c)
+			c.ClearExpiredData()
+			cmpCacheListOrder(t, c, tt.wantAfterClear)
+			checkInvariants(t, c)
+		})
+	}
+}
+
+// withExpiration returns the item with the expiration set to exp from now.
+func withExpiration(item Item, exp time.Duration) Item {
+	item.Expiration = time.Now().Add(exp)
+	return item
+}
+
+func TestExpiryQueue_CountBefore(t *testing.T) {
+	c := createCache(t, 100)
+	for i := 0; i < 100; i++ {
+		_ = c.Add(i, i, time.Duration(i-50)*time.Hour)
+	}
+	if got := c.expiries.countBefore(time.Now()); got != 50 {
+		t.Errorf("countBefore = %d, want 50", got)
+	}
+	if got := c.expiries.countBefore(time.Time{}); got != 0 {
+		t.Errorf("countBefore = %d, want 0", got)
+	}
+}
+
+func TestCache_ExpirationWriters(t *testing.T) {
+	tests := []struct {
+		name      string
+		write     func(c *Cache)
+		wantFound bool
+		wantMin   time.Duration
+		wantMax   time.Duration
+	}{
+		{
+			name: "Add with duration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "Add with zero duration never expires",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, 0)
+			},
+			wantFound: true,
+			wantMin:   NoExpiration,
+			wantMax:   NoExpiration,
+		},
+		{
+			name: "Add with negative duration is expired",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, -time.Minute)
+			},
+		},
+		{
+			name: "UpdateExpirationDate with duration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, 0)
+				_, _ = c.UpdateExpirationDate(k, time.Minute)
+				c.ClearExpiredData()
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "UpdateExpirationDate with zero duration never expires",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				_, _ = c.UpdateExpirationDate(k, 0)
+			},
+			wantFound: true,
+			wantMin:   NoExpiration,
+			wantMax:   NoExpiration,
+		},
+		{
+			name: "UpdateExpirationDate with NoExpiration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				_, _ = c.UpdateExpirationDate(k, NoExpiration)
+			},
+			wantFound: true,
+			wantMin:   NoExpiration,
+			wantMax:   NoExpiration,
+		},
+		{
+			name: "UpdateExpirationDate with KeepTTL",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				_, _ = c.UpdateExpirationDate(k, KeepTTL)
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "UpdateExpirationDate with negative duration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				_, _ = c.UpdateExpirationDate(k, -time.Minute)
+			},
+		},
+		{
+			name: "AddOrReplace with duration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, 0)
+				_ = c.AddOrReplace(k, v+v, time.Minute)
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "AddOrReplace with KeepTTL",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				_ = c.AddOrReplace(k, v+v, KeepTTL)
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "AddOrReplace with KeepTTL adds new data without expiration",
+			write: func(c *Cache) {
+				_ = c.AddOrReplace(k, v, KeepTTL)
+			},
+			wantFound: true,
+			wantMin:   NoExpiration,
+			wantMax:   NoExpiration,
+		},
+		{
+			name: "GetOrAdd",
+			write: func(c *Cache) {
+				_, _, _ = c.GetOrAdd(k, v, time.Minute)
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "GetOrLoad",
+			write: func(c *Cache) {
+				_, _ = c.GetOrLoad(k, func() (any, time.Duration, error) {
+					return v, time.Minute, nil
+				})
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "value updates keep expiration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				_ = c.Replace(k, v+v)
+				_, _ = c.UpdateVal(k, v+v+v)
+				_, _ = c.CompareAndSwap(k, v+v+v, v)
+				_, _ = c.Compute(k, func(old any, exists bool) (any, bool) {
+					return v + v, true
+				})
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+		{
+			name: "LoadFrom restores expiration",
+			write: func(c *Cache) {
+				_ = c.Add(k, v, time.Minute)
+				var buf bytes.Buffer
+				_ = c.SaveTo(&buf)
+				c.Clear()
+				_ = c.LoadFrom(&buf)
+			},
+			wantFound: true,
+			wantMin:   59 * time.Second,
+			wantMax:   time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			tt.write(c)
+			got, found := c.TTL(k)
+			if found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("TTL = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+			if item, ok := findItem(t, c, k); ok && item.Expired() != !tt.wantFound {
+				t.Errorf("Expired() = %v, want %v", item.Expired(), !tt.wantFound)
+			}
+			checkInvariants(t, c)
+		})
+	}
+}
+
+func TestCache_WritesToExpiredData(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(c *Cache) error
+		wantErr error
+	}{
+		{
+			name: "Replace",
+			write: func(c *Cache) error {
+				return c.Replace(k, v+v)
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "UpdateVal",
+			write: func(c *Cache) error {
+				_, err := c.UpdateVal(k, v+v)
+				return err
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "UpdateExpirationDate with duration",
+			write: func(c *Cache) error {
+				_, err := c.UpdateExpirationDate(k, time.Hour)
+				return err
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "UpdateExpirationDate with NoExpiration",
+			write: func(c *Cache) error {
+				_, err := c.UpdateExpirationDate(k, NoExpiration)
+				return err
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "UpdateExpirationDate with KeepTTL",
+			write: func(c *Cache) error {
+				_, err := c.UpdateExpirationDate(k, KeepTTL)
+				return err
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "CompareAndSwap",
+			write: func(c *Cache) error {
+				_, err := c.CompareAndSwap(k, v, v+v)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := cachetest.NewClock(time.Now())
+			c, err := New(5, WithClock(clock))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = c.Add(k, v, time.Second)
+			clock.Advance(2 * time.Second)
+
+			if err := tt.write(c); !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error, got %v, want %v", err, tt.wantErr)
+			}
+			if val, found := c.Get(k); found {
+				t.Errorf("expired data is written, got %v", val)
+			}
+			if c.Len() != 0 {
+				t.Errorf("expired data is not removed, length is %v", c.Len())
+			}
+			if n := c.Stats().Expirations; n != 1 {
+				t.Errorf("unexpected expiration count, got %v, want %v", n, 1)
+			}
+			checkInvariants(t, c)
+		})
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	c := createCache(t, 5)
+	if ttl, found := c.TTL(k); found || ttl != 0 {
+		t.Errorf("TTL of missing key = (%v, %v), want (0, false)", ttl, found)
+	}
+	_ = c.Add(k, v, time.Hour)
+	_ = c.Add(k+k, v, 0)
+	if ttl, _ := c.TTL(k); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL = %v, want about an hour", ttl)
+	}
+	if ttl, _ := c.TTL(k + k); ttl != NoExpiration {
+		t.Errorf("TTL = %v, want NoExpiration", ttl)
+	}
+	cmpCacheListOrder(t, c, []any{k + k, k})
+
+	s, err := NewSharded(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Add(k, v, time.Hour)
+	if ttl, found := s.TTL(k); !found || ttl <= 59*time.Minute {
+		t.Errorf("sharded TTL = (%v, %v), want about an hour", ttl, found)
+	}
+}
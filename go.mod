@@ -1,3 +1,3 @@
 module github.com/gozeloglu/cache
 
-go 1.18
+go 1.20
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+)
+
+// hashKey returns the hash of the given key. The hash is consistent with ==,
+// so equal keys always have the same hash. Strings, integers and floats are
+// hashed directly; other types are hashed by their contents through
+// reflection. Pointers and channels are hashed by their addresses, like they
+// are compared, and the positive and negative zeros have the same hash. NaN
+// keys are not equal to themselves, so they never match any data.
+//
+// The hash depends only on the seed and the key, so the same seed gives the
+// same hashes in every process, except for the pointers and the channels.
+func hashKey[K comparable](seed uint64, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hashString(seed, k)
+	case int:
+		return mix(uint64(k))
+	case int8:
+		return mix(uint64(k))
+	case int16:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case uint:
+		return mix(uint64(k))
+	case uint8:
+		return mix(uint64(k))
+	case uint16:
+		return mix(uint64(k))
+	case uint32:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	case uintptr:
+		return mix(uint64(k))
+	case float32:
+		return mix(floatBits(float64(k)))
+	case float64:
+		return mix(floatBits(k))
+	default:
+		return hashReflect(seed, key)
+	}
+}
+
+// hashReflect hashes the key through reflection. It is separate from hashKey,
+// so that the key escapes to the heap only for the types without a direct
+// hash.
+func hashReflect[K comparable](seed uint64, key K) uint64 {
+	h := seed
+	hashValue(&h, reflect.ValueOf(&key).Elem())
+	return h
+}
+
+// hashValue mixes the contents of v that == compares into h. The values of
+// the types which cannot be compared, such as slices, are not mixed, since
+// comparing them panics anyway.
+func hashValue(h *uint64, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		// The nil interface has no contents.
+	case reflect.Bool:
+		if v.Bool() {
+			*h = mix(*h ^ 1)
+		} else {
+			*h = mix(*h)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*h = mix(*h ^ uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		*h = mix(*h ^ v.Uint())
+	case reflect.Float32, reflect.Float64:
+		*h = mix(*h ^ floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		*h = mix(mix(*h^floatBits(real(c))) ^ floatBits(imag(c)))
+	case reflect.String:
+		*h = hashString(*h, v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		*h = mix(*h ^ uint64(v.Pointer()))
+	case reflect.Interface:
+		hashValue(h, v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			// Blank fields are ignored by ==.
+			if t.Field(i).Name != "_" {
+				hashValue(h, v.Field(i))
+			}
+		}
+	}
+}
+
+// hashString returns the hash of s. The bytes are mixed eight at a time, and
+// the length is mixed first, so the adjacent strings of structs and arrays
+// are separated.
+func hashString(seed uint64, s string) uint64 {
+	h := mix(seed ^ uint64(len(s)))
+	for ; len(s) >= 8; s = s[8:] {
+		h = mix(h ^ (uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+			uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56))
+	}
+	var tail uint64
+	for i := len(s) - 1; i >= 0; i-- {
+		tail = tail<<8 | uint64(s[i])
+	}
+	return mix(h ^ tail)
+}
+
+// newSeed returns a random hash seed. The random seeds keep the hashes of the
+// keys unpredictable, so the keys chosen by an attacker are not likely to
+// pile up in one shard.
+func newSeed() uint64 {
+	return rand.Uint64()
+}
+
+// floatBits returns the bits of f with the negative zero turned into the
+// positive zero, since they are equal.
+func floatBits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+// mix scrambles the bits of an integer key, so that sequential keys are
+// spread evenly across the shards. It is the finalizer of SplitMix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"math"
+	"testing"
+)
+
+type hashPoint struct {
+	X, Y int
+	_    int
+	Name string
+	Tag  any
+}
+
+func TestHashKey_ConsistentWithEquality(t *testing.T) {
+	seed := newSeed()
+	negZero := math.Copysign(0, -1)
+	p := &hashPoint{X: 1}
+	ch := make(chan int)
+	tests := []struct {
+		name string
+		a, b any
+	}{
+		{name: "float64 zeros", a: 0.0, b: negZero},
+		{name: "float32 zeros", a: float32(0), b: float32(negZero)},
+		{name: "complex zeros", a: complex(0, 0), b: complex(negZero, negZero)},
+		{name: "struct with float zeros", a: struct{ F float64 }{0}, b: struct{ F float64 }{negZero}},
+		{name: "struct with blank field", a: hashPoint{X: 1, Name: "a", Tag: 2}, b: hashPoint{X: 1, Name: "a", Tag: 2}},
+		{name: "arrays", a: [2]string{"a", "b"}, b: [2]string{"a", "b"}},
+		{name: "channels", a: ch, b: ch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.a != tt.b {
+				t.Fatalf("keys are not equal")
+			}
+			if hashKey(seed, tt.a) != hashKey(seed, tt.b) {
+				t.Errorf("equal keys have different hashes")
+			}
+		})
+	}
+
+	// A pointer is compared by its address, so its hash must not change
+	// when the pointee changes.
+	h := hashKey(seed, p)
+	p.X, p.Name = 2, "changed"
+	if hashKey(seed, p) != h {
+		t.Error("hash of pointer changes with its pointee")
+	}
+	if hashKey(seed, p) == hashKey(seed, &hashPoint{X: 2, Name: "changed"}) {
+		t.Error("different pointers to equal values have the same hash")
+	}
+}
+
+func TestShardedCache_PointerAndFloatKeys(t *testing.T) {
+	s, err := NewTypedSharded[any, int](16, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &hashPoint{X: 1}
+	_ = s.Add(p, 1, 0)
+	p.X = 2
+	if val, found := s.Get(p); !found || val != 1 {
+		t.Errorf("pointer key is not found after its pointee changes, got %v, %v", val, found)
+	}
+
+	_ = s.Add(0.0, 2, 0)
+	if val, found := s.Get(math.Copysign(0, -1)); !found || val != 2 {
+		t.Errorf("negative zero does not find positive zero key, got %v, %v", val, found)
+	}
+	if err := s.Add(math.Copysign(0, -1), 3, 0); err == nil {
+		t.Error("negative zero is added next to equal positive zero key")
+	}
+}
+
+func BenchmarkHashKey_Struct(b *testing.B) {
+	seed := newSeed()
+	key := hashPoint{X: 1, Y: 2, Name: "point"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hashKey(seed, key)
+	}
+}
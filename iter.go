@@ -0,0 +1,51 @@
+//go:build go1.23
+
+package cache
+
+import (
+	"iter"
+	"time"
+)
+
+// All returns an iterator over the keys and the values of the unexpired data
+// from the most recently used to the least recently used one. It does not
+// change the access order or the statistics. The read lock is held during the
+// loop, so the loop body must not call the methods of the cache; Items can be
+// used to change the cache while iterating.
+func (c *TypedCache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c.Range(func(key K, val V, _ time.Time) bool {
+			return yield(key, val)
+		})
+	}
+}
+
+// Backward is like All, but it iterates from the least recently used data to
+// the most recently used one.
+func (c *TypedCache[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c.RangeBackward(func(key K, val V, _ time.Time) bool {
+			return yield(key, val)
+		})
+	}
+}
+
+// All returns an iterator over the keys and the values of the unexpired data
+// of all shards in the order of Range. The loop body must not call the
+// methods of the cache.
+func (s *TypedShardedCache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		s.Range(func(key K, val V, _ time.Time) bool {
+			return yield(key, val)
+		})
+	}
+}
+
+// Backward is like All, but it iterates in the order of RangeBackward.
+func (s *TypedShardedCache[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		s.RangeBackward(func(key K, val V, _ time.Time) bool {
+			return yield(key, val)
+		})
+	}
+}
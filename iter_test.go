@@ -0,0 +1,55 @@
+//go:build go1.23
+
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCache_All(t *testing.T) {
+	c, _ := createRangeCache(t)
+
+	var keys []int
+	for key, val := range c.All() {
+		keys = append(keys, key)
+		if val == "three" {
+			break
+		}
+	}
+	if want := []int{4, 3}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("unexpected keys, got %v, want %v", keys, want)
+	}
+
+	var vals []string
+	for _, val := range c.Backward() {
+		vals = append(vals, val)
+	}
+	if want := []string{"one", "two", "three", "four"}; !reflect.DeepEqual(vals, want) {
+		t.Errorf("unexpected values, got %v, want %v", vals, want)
+	}
+}
+
+func TestShardedCache_All(t *testing.T) {
+	c := createShardedCache(t, 4, 100)
+	for i := 0; i < 50; i++ {
+		_ = c.Add(i, i, 0)
+	}
+
+	var n, m int
+	for key, val := range c.All() {
+		if key != val {
+			t.Errorf("unexpected value of %v, got %v", key, val)
+		}
+		n++
+	}
+	for range c.Backward() {
+		m++
+		if m == 5 {
+			break
+		}
+	}
+	if n != 50 || m != 5 {
+		t.Errorf("unexpected iteration count, got %v and %v", n, m)
+	}
+}
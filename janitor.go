@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor clears the expired data of a cache periodically in the background.
+type janitor struct {
+	// interval is the period of the cleanup.
+	interval time.Duration
+
+	// stop is closed to signal the goroutine to return.
+	stop chan struct{}
+
+	// done is closed when the goroutine returns.
+	done chan struct{}
+
+	// once guards stop against closing more than once.
+	once sync.Once
+}
+
+// newJanitor creates a janitor and starts its goroutine which calls clean in
+// every interval.
+func newJanitor(interval time.Duration, clean func()) *janitor {
+	j := &janitor{
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go j.run(clean)
+	return j
+}
+
+// run calls clean in every interval until the janitor is stopped.
+func (j *janitor) run(clean func()) {
+	defer close(j.done)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			clean()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// close stops the goroutine and waits for it to return. It is safe to call
+// multiple times.
+func (j *janitor) close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it returns true or the timeout is exceeded. It is a
+// helper function to prevent code duplication.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestCache_CleanupInterval(t *testing.T) {
+	tests := []struct {
+		name       string
+		interval   time.Duration
+		addPairs   [][]any
+		wantLength int
+	}{
+		{
+			name:       "clears expired data in background",
+			interval:   time.Millisecond,
+			addPairs:   [][]any{{k, v, time.Millisecond}, {k + k, v + v, time.Hour}, {k + k + k, v + v + v, -1 * time.Hour}},
+			wantLength: 1,
+		},
+		{
+			name:       "keeps unexpired data",
+			interval:   time.Millisecond,
+			addPairs:   [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, time.Hour}},
+			wantLength: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(3, WithCleanupInterval(tt.interval))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			addItemsWithExp(t, c, tt.addPairs)
+			ok := waitFor(t, time.Second, func() bool {
+				return c.Len() == tt.wantLength
+			})
+			if !ok {
+				t.Errorf("unexpected length after cleanup, got %v, want %v", c.Len(), tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestCache_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	caches := make([]*Cache, 10)
+	for i := range caches {
+		c, err := New(1, WithCleanupInterval(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		caches[i] = c
+	}
+	if got := runtime.NumGoroutine(); got < before+len(caches) {
+		t.Errorf("expected cleanup goroutines to be started, got %v goroutines, want at least %v", got, before+len(caches))
+	}
+
+	for _, c := range caches {
+		c.Close()
+		c.Close()
+	}
+	ok := waitFor(t, time.Second, func() bool {
+		return runtime.NumGoroutine() <= before
+	})
+	if !ok {
+		t.Errorf("goroutine leak, got %v goroutines, want %v", runtime.NumGoroutine(), before)
+	}
+
+	// Close must also be safe for a cache without cleanup goroutine.
+	c := createCache(t, 1)
+	c.Close()
+	c.Close()
+}
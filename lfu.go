@@ -0,0 +1,155 @@
+package cache
+
+// lfu evicts the least frequently used key in constant time. The keys with
+// the same frequency are kept in a bucket in LRU order, and the buckets are
+// kept in a list in increasing frequency order. The removed nodes and the
+// emptied buckets are kept in free lists, so the accesses and the evictions
+// do not allocate.
+type lfu[K comparable] struct {
+	// root is the sentinel bucket. root.next is the bucket of the lowest
+	// frequency.
+	root lfuBucket[K]
+
+	// nodes maps the keys to their nodes.
+	nodes map[K]*lfuNode[K]
+
+	// freeNodes and freeBuckets are the heads of the free lists linked by
+	// their next fields.
+	freeNodes   *lfuNode[K]
+	freeBuckets *lfuBucket[K]
+}
+
+// lfuBucket holds the keys with the same frequency in a circular list with a
+// sentinel root, from the most recently used one to the least recently used
+// one.
+type lfuBucket[K comparable] struct {
+	freq       int
+	root       lfuNode[K]
+	len        int
+	prev, next *lfuBucket[K]
+}
+
+// lfuNode is a key in a bucket.
+type lfuNode[K comparable] struct {
+	key        K
+	bucket     *lfuBucket[K]
+	prev, next *lfuNode[K]
+}
+
+// newLFU creates an empty lfu policy.
+func newLFU[K comparable]() *lfu[K] {
+	l := &lfu[K]{nodes: make(map[K]*lfuNode[K])}
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	return l
+}
+
+// add puts the key into the bucket of frequency one.
+func (l *lfu[K]) add(key K) {
+	b := l.root.next
+	if b == &l.root || b.freq != 1 {
+		b = l.newBucket(1, &l.root)
+	}
+	n := l.freeNodes
+	if n != nil {
+		l.freeNodes = n.next
+	} else {
+		n = &lfuNode[K]{}
+	}
+	n.key = key
+	b.push(n)
+	l.nodes[key] = n
+}
+
+// access moves the key to the bucket of the next frequency.
+func (l *lfu[K]) access(key K) {
+	n, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	cur := n.bucket
+	next := cur.next
+	if next == &l.root || next.freq != cur.freq+1 {
+		next = l.newBucket(cur.freq+1, cur)
+	}
+	cur.unlink(n)
+	next.push(n)
+	l.dropIfEmpty(cur)
+}
+
+// remove forgets the key.
+func (l *lfu[K]) remove(key K, reason EvictReason) {
+	n, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	b := n.bucket
+	b.unlink(n)
+	l.dropIfEmpty(b)
+	delete(l.nodes, key)
+	*n = lfuNode[K]{next: l.freeNodes}
+	l.freeNodes = n
+}
+
+// victim returns the least recently used key of the lowest frequency.
+func (l *lfu[K]) victim() K {
+	return l.root.next.root.prev.key
+}
+
+func (l *lfu[K]) resize(int) {}
+
+// clear forgets all keys. The nodes and the buckets are kept for reuse.
+func (l *lfu[K]) clear() {
+	for key := range l.nodes {
+		l.remove(key, ReasonCleared)
+	}
+}
+
+// newBucket inserts an empty bucket of the given frequency after prev and
+// returns it.
+func (l *lfu[K]) newBucket(freq int, prev *lfuBucket[K]) *lfuBucket[K] {
+	b := l.freeBuckets
+	if b != nil {
+		l.freeBuckets = b.next
+	} else {
+		b = &lfuBucket[K]{}
+	}
+	b.freq = freq
+	b.root.next = &b.root
+	b.root.prev = &b.root
+	b.prev = prev
+	b.next = prev.next
+	b.prev.next = b
+	b.next.prev = b
+	return b
+}
+
+// dropIfEmpty removes the bucket from the list if it has no keys and keeps
+// it in the free list.
+func (l *lfu[K]) dropIfEmpty(b *lfuBucket[K]) {
+	if b.len > 0 {
+		return
+	}
+	b.prev.next = b.next
+	b.next.prev = b.prev
+	*b = lfuBucket[K]{next: l.freeBuckets}
+	l.freeBuckets = b
+}
+
+// push adds n to the front of the bucket.
+func (b *lfuBucket[K]) push(n *lfuNode[K]) {
+	n.bucket = b
+	n.prev = &b.root
+	n.next = b.root.next
+	n.prev.next = n
+	n.next.prev = n
+	b.len++
+}
+
+// unlink removes n from the bucket.
+func (b *lfuBucket[K]) unlink(n *lfuNode[K]) {
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	n.prev, n.next, n.bucket = nil, nil, nil
+	b.len--
+}
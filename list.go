@@ -0,0 +1,148 @@
+package cache
+
+// entry is a node of the list that stores the cached data. The item and the
+// links are stored in the same node, so moving the data or changing its value
+// does not allocate.
+type entry[K comparable, V any] struct {
+	// item is the cached data.
+	item TypedItem[K, V]
+
+	// prev and next link the entry to its neighbors in the list.
+	prev, next *entry[K, V]
+
+	// index is the index of the entry in the expiry queue. It is -1 if the
+	// entry is not in the queue.
+	index int
+}
+
+// entryList is a doubly-linked circular list of entries with a sentinel root,
+// ordered from the most recently used data to the least recently used one.
+// Unlike container/list, it does not box the items in interfaces, and the
+// removed entries are kept in a free list and reused by pushFront, so a full
+// cache does not allocate for the new data. The zero value is not usable; init
+// must be called first.
+type entryList[K comparable, V any] struct {
+	// root is the sentinel entry. root.next is the front and root.prev is
+	// the back of the list.
+	root entry[K, V]
+
+	// len is the number of entries in the list.
+	len int
+
+	// free is the head of the removed entries linked by their next fields.
+	free *entry[K, V]
+
+	// nfree is the number of entries in the free list.
+	nfree int
+
+	// limit is the maximum number of entries in the list and the free list
+	// together. The removed entries beyond it are left to the garbage
+	// collector.
+	limit int
+}
+
+// init initializes the empty list which keeps up to limit entries.
+func (l *entryList[K, V]) init(limit int) {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	l.limit = limit
+}
+
+// front returns the first entry of the list or nil if the list is empty.
+func (l *entryList[K, V]) front() *entry[K, V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
+// back returns the last entry of the list or nil if the list is empty.
+func (l *entryList[K, V]) back() *entry[K, V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// next returns the entry after e or nil if e is the last one.
+func (l *entryList[K, V]) next(e *entry[K, V]) *entry[K, V] {
+	if e.next == &l.root {
+		return nil
+	}
+	return e.next
+}
+
+// prev returns the entry before e or nil if e is the first one.
+func (l *entryList[K, V]) prev(e *entry[K, V]) *entry[K, V] {
+	if e.prev == &l.root {
+		return nil
+	}
+	return e.prev
+}
+
+// pushFront inserts a new entry holding item at the front of the list and
+// returns it. The entry is taken from the free list if there is one.
+func (l *entryList[K, V]) pushFront(item TypedItem[K, V]) *entry[K, V] {
+	e := l.free
+	if e != nil {
+		l.free = e.next
+		l.nfree--
+	} else {
+		e = &entry[K, V]{}
+	}
+	e.item = item
+	e.index = -1
+	l.link(e)
+	l.len++
+	return e
+}
+
+// moveToFront moves e to the front of the list.
+func (l *entryList[K, V]) moveToFront(e *entry[K, V]) {
+	if l.root.next == e {
+		return
+	}
+	l.unlink(e)
+	l.link(e)
+}
+
+// remove removes e from the list and returns its item. The entry must not be
+// used after it is removed, since it may be reused by pushFront.
+func (l *entryList[K, V]) remove(e *entry[K, V]) TypedItem[K, V] {
+	item := e.item
+	l.unlink(e)
+	l.len--
+	*e = entry[K, V]{}
+	if l.len+l.nfree < l.limit {
+		e.next = l.free
+		l.free = e
+		l.nfree++
+	}
+	return item
+}
+
+// setLimit changes the maximum number of the entries and drops the free
+// entries beyond it.
+func (l *entryList[K, V]) setLimit(limit int) {
+	l.limit = limit
+	for l.free != nil && l.len+l.nfree > limit {
+		e := l.free
+		l.free = e.next
+		e.next = nil
+		l.nfree--
+	}
+}
+
+// link inserts e after the root.
+func (l *entryList[K, V]) link(e *entry[K, V]) {
+	e.prev = &l.root
+	e.next = l.root.next
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// unlink removes e from its neighbors.
+func (l *entryList[K, V]) unlink(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+// listKeys returns the keys of the list from the front to the back and checks
+// that walking the list backward gives the same keys. It is a helper function
+// to prevent code duplication.
+func listKeys(t *testing.T, l *entryList[int, int]) []int {
+	t.Helper()
+	var keys, back []int
+	for e := l.front(); e != nil; e = l.next(e) {
+		keys = append(keys, e.item.Key)
+	}
+	for e := l.back(); e != nil; e = l.prev(e) {
+		back = append([]int{e.item.Key}, back...)
+	}
+	if !reflect.DeepEqual(keys, back) {
+		t.Errorf("backward keys = %v, want %v", back, keys)
+	}
+	if len(keys) != l.len {
+		t.Errorf("len = %d, want %d", l.len, len(keys))
+	}
+	return keys
+}
+
+func TestEntryList(t *testing.T) {
+	var l entryList[int, int]
+	l.init(3)
+	if l.front() != nil || l.back() != nil {
+		t.Fatal("empty list has entries")
+	}
+
+	a := l.pushFront(TypedItem[int, int]{Key: 1})
+	l.pushFront(TypedItem[int, int]{Key: 2})
+	c := l.pushFront(TypedItem[int, int]{Key: 3})
+	if got := listKeys(t, &l); !reflect.DeepEqual(got, []int{3, 2, 1}) {
+		t.Errorf("keys = %v, want [3 2 1]", got)
+	}
+	if a.index != -1 {
+		t.Errorf("index = %d, want -1", a.index)
+	}
+
+	l.moveToFront(a)
+	l.moveToFront(a)
+	if got := listKeys(t, &l); !reflect.DeepEqual(got, []int{1, 3, 2}) {
+		t.Errorf("keys = %v, want [1 3 2]", got)
+	}
+
+	if item := l.remove(c); item.Key != 3 {
+		t.Errorf("removed key = %d, want 3", item.Key)
+	}
+	if l.nfree != 1 || l.free != c {
+		t.Fatalf("removed entry is not kept for reuse, free %d", l.nfree)
+	}
+	if e := l.pushFront(TypedItem[int, int]{Key: 4}); e != c || l.nfree != 0 {
+		t.Error("free entry is not reused")
+	}
+	if got := listKeys(t, &l); !reflect.DeepEqual(got, []int{4, 1, 2}) {
+		t.Errorf("keys = %v, want [4 1 2]", got)
+	}
+
+	l.remove(l.back())
+	l.remove(l.back())
+	if l.nfree != 2 {
+		t.Errorf("free = %d, want 2", l.nfree)
+	}
+	l.setLimit(1)
+	if l.nfree != 0 {
+		t.Errorf("free = %d, want the free entries beyond the limit dropped", l.nfree)
+	}
+	l.remove(l.front())
+	if l.front() != nil || l.nfree != 1 {
+		t.Errorf("list is not empty or the entry is not kept, free %d", l.nfree)
+	}
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// loader deduplicates the concurrent loads of the same key and caches the
+// loader errors for the negative TTL.
+type loader[K comparable, V any] struct {
+	// mu protects calls and failures. It is never held together with the
+	// cache mutex.
+	mu sync.Mutex
+
+	// calls holds the in-flight loads by key.
+	calls map[K]*call[V]
+
+	// failures holds the cached loader errors by key.
+	failures map[K]failure
+
+	// negativeTTL is the duration that the loader errors are cached for.
+	negativeTTL time.Duration
+}
+
+// call is an in-flight load. The callers waiting for the load block on wg.
+type call[V any] struct {
+	wg  sync.WaitGroup
+	val V
+	err error
+}
+
+// failure is a cached loader error.
+type failure struct {
+	err error
+
+	// expiration is the time that the error expires.
+	expiration time.Time
+}
+
+// GetOrLoad returns the value of the given key. If the key does not exist, the
+// value is loaded by calling loader, and it is added to the cache with the
+// returned expiration duration. Zero duration means the default TTL, which is
+// no expiration unless WithDefaultTTL is given. Concurrent calls for the same
+// key share a single load, so loader is called once and every caller gets its
+// result.
+//
+// The error returned by loader is returned as is, and the value is not
+// cached. If the cache is created WithNegativeTTL, the error is also cached,
+// and it is returned without calling loader until the negative TTL passes. If
+// the loaded value cannot be added, for example its cost exceeds the maximum
+// cost, the value is returned with the error. If loader panics, the panic is
+// propagated to its caller and ErrLoaderPanic is returned to the others.
+func (c *TypedCache[K, V]) GetOrLoad(key K, loader func() (V, time.Duration, error)) (V, error) {
+	if val, found := c.Get(key); found {
+		return val, nil
+	}
+
+	l := &c.loader
+	l.mu.Lock()
+	if err := l.failed(key, c.clock.Now()); err != nil {
+		l.mu.Unlock()
+		var zero V
+		return zero, err
+	}
+	if cl, found := l.calls[key]; found {
+		l.mu.Unlock()
+		cl.wg.Wait()
+		return cl.val, cl.err
+	}
+	cl := &call[V]{}
+	cl.wg.Add(1)
+	if l.calls == nil {
+		l.calls = make(map[K]*call[V])
+	}
+	l.calls[key] = cl
+	l.mu.Unlock()
+
+	c.load(key, cl, loader)
+	return cl.val, cl.err
+}
+
+// load calls loader for cl and adds the loaded value to the cache. The value
+// may be added by a load that finished after the caller missed the key, so the
+// cache is checked again before calling loader.
+func (c *TypedCache[K, V]) load(key K, cl *call[V], loader func() (V, time.Duration, error)) {
+	var ttl time.Duration
+	var cacheErr bool
+	returned := false
+	defer func() {
+		if !returned {
+			cl.err = keyError(key, ErrLoaderPanic)
+		}
+		c.loader.done(key, cl, cacheErr, c.clock.Now())
+	}()
+
+	if item, found := c.peek(key); found {
+		cl.val = item.Val
+		returned = true
+		return
+	}
+	cl.val, ttl, cl.err = loader()
+	returned = true
+	if cl.err != nil {
+		cacheErr = true
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	cl.err = c.set(c.newItem(key, cl.val, ttl))
+}
+
+// done removes the finished call and wakes up its waiters. If cacheErr is
+// true and there is a negative TTL, the error of the call is cached from now.
+func (l *loader[K, V]) done(key K, cl *call[V], cacheErr bool, now time.Time) {
+	l.mu.Lock()
+	delete(l.calls, key)
+	if cacheErr && l.negativeTTL > 0 {
+		if l.failures == nil {
+			l.failures = make(map[K]failure)
+		}
+		l.failures[key] = failure{
+			err:        cl.err,
+			expiration: now.Add(l.negativeTTL),
+		}
+	}
+	l.mu.Unlock()
+	cl.wg.Done()
+}
+
+// failed returns the cached error of the given key, or nil if there is no
+// such an error. The expired error is removed. mu must be held.
+func (l *loader[K, V]) failed(key K, now time.Time) error {
+	f, found := l.failures[key]
+	if !found {
+		return nil
+	}
+	if !now.Before(f.expiration) {
+		delete(l.failures, key)
+		return nil
+	}
+	return f.err
+}
+
+// clearFailures removes the cached errors that expire before now. The zero
+// time removes all of them.
+func (l *loader[K, V]) clearFailures(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, f := range l.failures {
+		if now.IsZero() || !now.Before(f.expiration) {
+			delete(l.failures, key)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+var errLoad = errors.New("load failed")
+
+func TestCache_GetOrLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		addPairs  [][]any
+		loader    func() (any, time.Duration, error)
+		wantVal   any
+		wantErr   error
+		wantLoads int32
+		wantFound bool
+	}{
+		{
+			name:     "returns existing data without loading",
+			addPairs: [][]any{{k, v, 0 * time.Hour}},
+			loader: func() (any, time.Duration, error) {
+				return v + v, 0, nil
+			},
+			wantVal:   v,
+			wantLoads: 0,
+			wantFound: true,
+		},
+		{
+			name: "loads and adds missing data",
+			loader: func() (any, time.Duration, error) {
+				return v, time.Hour, nil
+			},
+			wantVal:   v,
+			wantLoads: 1,
+			wantFound: true,
+		},
+		{
+			name:     "loads expired data again",
+			addPairs: [][]any{{k, v, -1 * time.Hour}},
+			loader: func() (any, time.Duration, error) {
+				return v + v, 0, nil
+			},
+			wantVal:   v + v,
+			wantLoads: 1,
+			wantFound: true,
+		},
+		{
+			name: "returns loader error without adding",
+			loader: func() (any, time.Duration, error) {
+				return nil, 0, errLoad
+			},
+			wantErr:   errLoad,
+			wantLoads: 1,
+			wantFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			addItemsWithExp(t, c, tt.addPairs)
+			var loads atomic.Int32
+			val, err := c.GetOrLoad(k, func() (any, time.Duration, error) {
+				loads.Add(1)
+				return tt.loader()
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && val != tt.wantVal {
+				t.Errorf("val = %v, want %v", val, tt.wantVal)
+			}
+			if got := loads.Load(); got != tt.wantLoads {
+				t.Errorf("loads = %d, want %d", got, tt.wantLoads)
+			}
+			if found := c.Contains(k); found != tt.wantFound {
+				t.Errorf("Contains = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCache_GetOrLoadTTL(t *testing.T) {
+	c := createCache(t, 5)
+	_, err := c.GetOrLoad(k, func() (any, time.Duration, error) {
+		return v, time.Hour, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, _ := findItem(t, c, k)
+	if until := time.Until(item.Expiration); until <= 0 || until > time.Hour {
+		t.Errorf("expires in %v, want at most %v", until, time.Hour)
+	}
+}
+
+func TestCache_GetOrLoadDeduplicates(t *testing.T) {
+	c := createCache(t, 5)
+	const callers = 50
+	var loads atomic.Int32
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	var wg sync.WaitGroup
+	vals := make([]any, callers)
+	errs := make([]error, callers)
+	started.Add(callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			vals[i], errs[i] = c.GetOrLoad(k, func() (any, time.Duration, error) {
+				loads.Add(1)
+				<-release
+				return v, 0, nil
+			})
+		}(i)
+	}
+	started.Wait()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("loads = %d, want 1", got)
+	}
+	for i := range vals {
+		if errs[i] != nil || vals[i] != v {
+			t.Errorf("caller %d got (%v, %v), want (%v, nil)", i, vals[i], errs[i], v)
+		}
+	}
+}
+
+func TestCache_GetOrLoadNegativeTTL(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := New(5, WithNegativeTTL(50*time.Millisecond), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loads atomic.Int32
+	loader := func() (any, time.Duration, error) {
+		if loads.Add(1) == 1 {
+			return nil, 0, errLoad
+		}
+		return v, 0, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad(k, loader); !errors.Is(err, errLoad) {
+			t.Fatalf("err = %v, want %v", err, errLoad)
+		}
+	}
+	if got := loads.Load(); got != 1 {
+		t.Fatalf("loads = %d, want 1 while the error is cached", got)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	val, err := c.GetOrLoad(k, loader)
+	if err != nil || val != v {
+		t.Errorf("got (%v, %v), want (%v, nil) after the negative TTL", val, err, v)
+	}
+	if got := loads.Load(); got != 2 {
+		t.Errorf("loads = %d, want 2", got)
+	}
+}
+
+func TestCache_GetOrLoadNegativeTTLCleared(t *testing.T) {
+	c, err := New(5, WithNegativeTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = c.GetOrLoad(k, func() (any, time.Duration, error) {
+		return nil, 0, errLoad
+	})
+	c.Clear()
+	val, err := c.GetOrLoad(k, func() (any, time.Duration, error) {
+		return v, 0, nil
+	})
+	if err != nil || val != v {
+		t.Errorf("got (%v, %v), want (%v, nil) after Clear", val, err, v)
+	}
+}
+
+func TestCache_GetOrLoadPanic(t *testing.T) {
+	c := createCache(t, 5)
+	release := make(chan struct{})
+	waiterErr := make(chan error, 1)
+	loading := make(chan struct{})
+
+	go func() {
+		defer func() { _ = recover() }()
+		_, _ = c.GetOrLoad(k, func() (any, time.Duration, error) {
+			close(loading)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-loading
+	go func() {
+		_, err := c.GetOrLoad(k, func() (any, time.Duration, error) {
+			return v, 0, nil
+		})
+		waiterErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waiterErr:
+		if err != nil && !errors.Is(err, ErrLoaderPanic) {
+			t.Errorf("err = %v, want nil or %v", err, ErrLoaderPanic)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter is stuck after the loader panicked")
+	}
+
+	val, err := c.GetOrLoad(k, func() (any, time.Duration, error) {
+		return v, 0, nil
+	})
+	if err != nil || val != v {
+		t.Errorf("got (%v, %v), want (%v, nil) after the panic", val, err, v)
+	}
+}
+
+func TestCache_GetOrLoadCostTooLarge(t *testing.T) {
+	c, err := New(5, WithMaxCost(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetWeigher(func(key, val any) int64 { return 3 })
+	val, err := c.GetOrLoad(k, func() (any, time.Duration, error) {
+		return v, 0, nil
+	})
+	if !errors.Is(err, ErrCostTooLarge) || val != v {
+		t.Errorf("got (%v, %v), want (%v, %v)", val, err, v, ErrCostTooLarge)
+	}
+	if c.Contains(k) {
+		t.Error("data exceeding the maximum cost is added")
+	}
+}
+
+func TestShardedCache_GetOrLoad(t *testing.T) {
+	s, err := NewSharded(4, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var loads atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := i % 4
+			val, err := s.GetOrLoad(key, func() (any, time.Duration, error) {
+				loads.Add(1)
+				return key * 10, 0, nil
+			})
+			if err != nil || val != key*10 {
+				t.Errorf("got (%v, %v), want (%v, nil)", val, err, key*10)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := loads.Load(); got != 4 {
+		t.Errorf("loads = %d, want 4", got)
+	}
+	if s.Len() != 4 {
+		t.Errorf("Len = %d, want 4", s.Len())
+	}
+}
@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures the optional behaviors of the cache. Options are passed
+// to NewWithOptions, New and NewTyped.
+type Option func(*Config)
+
+// Config is the configuration of a cache built from the options. The
+// effective configuration of a cache is returned by its Config method, where
+// the unset settings hold their default values.
+type Config struct {
+	// Capacity is the maximum number of the cached data.
+	Capacity int
+
+	// CleanupInterval is the period of the background expired data cleanup.
+	// Zero means that there is no background cleanup.
+	CleanupInterval time.Duration
+
+	// Policy is the eviction policy.
+	Policy Policy
+
+	// MaxCost is the maximum total cost of the cached data. Zero means that
+	// there is no cost limit.
+	MaxCost int64
+
+	// NegativeTTL is the duration that the loader errors are cached for.
+	// Zero means that the errors are not cached.
+	NegativeTTL time.Duration
+
+	// Codec encodes and decodes the snapshots and the log records.
+	Codec Codec
+
+	// WALPath is the path of the write-ahead log. Empty path means that
+	// there is no log.
+	WALPath string
+
+	// SyncPolicy decides when the log is flushed to the disk.
+	SyncPolicy SyncPolicy
+
+	// SyncInterval is the flush period of SyncInterval.
+	SyncInterval time.Duration
+
+	// RefreshAhead is the fraction of the TTL after which the data is
+	// refreshed. Zero means that there is no refresh-ahead.
+	RefreshAhead float64
+
+	// StaleWindow is the duration after the expiration during which the
+	// expired data is returned while it is refreshed.
+	StaleWindow time.Duration
+
+	// DefaultTTL is the expiration duration of the data added with zero
+	// duration. Zero means that such data never expires.
+	DefaultTTL time.Duration
+
+	// TTLJitter is the fraction that the TTLs are randomly changed by.
+	TTLJitter float64
+
+	// SlidingExpiration reports whether Get pushes the expiration forward.
+	SlidingExpiration bool
+
+	// Clock tells the current time.
+	Clock Clock
+
+	// OnEvict is the eviction callback. It is a func(key K, val V, reason
+	// EvictReason) of the key and the value types of the cache, or nil.
+	OnEvict any
+
+	// Weigher computes the cost of the data. It is a func(key K, val V)
+	// int64 of the key and the value types of the cache, or nil.
+	Weigher any
+
+	// Loader refreshes the data in the background. It is a func(key K) (V,
+	// time.Duration, error) of the key and the value types of the cache, or
+	// nil.
+	Loader any
+}
+
+// newConfig applies opts to an empty configuration, fills the unset settings
+// with their default values and validates the result.
+func newConfig(opts []Option) (Config, error) {
+	var cfg Config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = GobCodec
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock
+	}
+	if cfg.SyncInterval == 0 {
+		cfg.SyncInterval = defaultSyncInterval
+	}
+	if cfg.DefaultTTL == NoExpiration {
+		cfg.DefaultTTL = 0
+	}
+	return cfg, cfg.validate()
+}
+
+// validate checks the settings which cannot be corrected silently.
+func (cfg Config) validate() error {
+	if cfg.Capacity == 0 {
+		return ErrZeroCapacity
+	}
+	if cfg.Capacity < 0 {
+		return ErrNegativeCapacity
+	}
+	if cfg.Policy < PolicyLRU || cfg.Policy > PolicyTinyLFU {
+		return fmt.Errorf("%w: unknown eviction policy %v", ErrInvalidConfig, cfg.Policy)
+	}
+	if cfg.SyncPolicy < SyncInterval || cfg.SyncPolicy > SyncNever {
+		return fmt.Errorf("%w: unknown sync policy %v", ErrInvalidConfig, cfg.SyncPolicy)
+	}
+	if cfg.CleanupInterval < 0 {
+		return fmt.Errorf("%w: cleanup interval %v is negative", ErrInvalidConfig, cfg.CleanupInterval)
+	}
+	if cfg.SyncInterval < 0 {
+		return fmt.Errorf("%w: sync interval %v is negative", ErrInvalidConfig, cfg.SyncInterval)
+	}
+	if cfg.MaxCost < 0 {
+		return fmt.Errorf("%w: maximum cost %d is negative", ErrInvalidConfig, cfg.MaxCost)
+	}
+	if cfg.NegativeTTL < 0 {
+		return fmt.Errorf("%w: negative TTL %v is less than zero", ErrInvalidConfig, cfg.NegativeTTL)
+	}
+	if cfg.RefreshAhead < 0 || cfg.RefreshAhead >= 1 {
+		return fmt.Errorf("%w: refresh-ahead fraction %v is not in [0, 1)", ErrInvalidConfig, cfg.RefreshAhead)
+	}
+	if cfg.StaleWindow < 0 {
+		return fmt.Errorf("%w: stale window %v is negative", ErrInvalidConfig, cfg.StaleWindow)
+	}
+	if cfg.DefaultTTL < 0 {
+		return fmt.Errorf("%w: default TTL %v is negative", ErrInvalidConfig, cfg.DefaultTTL)
+	}
+	if cfg.TTLJitter < 0 || cfg.TTLJitter >= 1 {
+		return fmt.Errorf("%w: TTL jitter %v is not in [0, 1)", ErrInvalidConfig, cfg.TTLJitter)
+	}
+	return nil
+}
+
+// configFunc returns the callback fn of the configuration as F, or the zero
+// F if fn is nil. The options of the callbacks are not bound to the key and
+// the value types of the cache, so the types are checked here.
+func configFunc[F any](name string, fn any) (F, error) {
+	var zero F
+	if fn == nil {
+		return zero, nil
+	}
+	f, ok := fn.(F)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s %T does not match the key and value types", ErrInvalidConfig, name, fn)
+	}
+	return f, nil
+}
+
+// WithCapacity sets the maximum number of the cached data. It is required by
+// NewWithOptions, and the capacity needs to be more than zero.
+func WithCapacity(cap int) Option {
+	return func(o *Config) {
+		o.Capacity = cap
+	}
+}
+
+// WithCleanupInterval starts a background goroutine that clears the expired
+// data in every given interval. The goroutine is stopped by calling Close.
+// Zero means that no goroutine is started, and a negative interval returns
+// ErrInvalidConfig.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *Config) {
+		o.CleanupInterval = interval
+	}
+}
+
+// WithPolicy sets the eviction policy of the cache. The default policy is
+// PolicyLRU.
+func WithPolicy(p Policy) Option {
+	return func(o *Config) {
+		o.Policy = p
+	}
+}
+
+// WithMaxCost limits the total cost of the cached data in addition to the
+// capacity. The cost of a data is given by the weigher given WithWeigher or
+// registered with SetWeigher, or it is one if there is no weigher. Zero means that there is
+// no cost limit, and a negative maximum cost returns ErrInvalidConfig.
+func WithMaxCost(maxCost int64) Option {
+	return func(o *Config) {
+		o.MaxCost = maxCost
+	}
+}
+
+// WithNegativeTTL caches the errors returned by the GetOrLoad loaders for the
+// given duration, so a failing key is not loaded again until the duration
+// passes. Zero means that the errors are not cached, and a negative duration
+// returns ErrInvalidConfig.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *Config) {
+		o.NegativeTTL = ttl
+	}
+}
+
+// WithCodec sets the codec of the snapshots written by SaveTo and read by
+// LoadFrom. The default codec is GobCodec, which is also used if codec is nil.
+func WithCodec(codec Codec) Option {
+	return func(o *Config) {
+		o.Codec = codec
+	}
+}
+
+// WithWAL records every change of the cache to the append-only write-ahead log
+// at path. If the log exists, it is replayed when the cache is created, so the
+// data survives a restart or a crash. The log is compacted when it grows more
+// than twice as long as the cache. The keys and the values are encoded with
+// the codec of the cache. The capacity is not restored from the log; the
+// capacity given to the constructor is kept even if Resize changed it before
+// the restart, and the logged data that does not fit is evicted while the log
+// is replayed. Close flushes and closes the log.
+func WithWAL(path string) Option {
+	return func(o *Config) {
+		o.WALPath = path
+	}
+}
+
+// WithSyncPolicy sets when the write-ahead log is flushed to the disk. The
+// default policy is SyncInterval.
+func WithSyncPolicy(p SyncPolicy) Option {
+	return func(o *Config) {
+		o.SyncPolicy = p
+	}
+}
+
+// WithSyncInterval sets the flush period of SyncInterval. Zero means that the
+// log is flushed every second, and a negative interval returns
+// ErrInvalidConfig.
+func WithSyncInterval(interval time.Duration) Option {
+	return func(o *Config) {
+		o.SyncInterval = interval
+	}
+}
+
+// WithRefreshAhead refreshes the data in the background when Get is called
+// after the given fraction of its TTL passes. For example, 0.8 refreshes the
+// data added with a one minute TTL after 48 seconds. Get returns the current
+// value without waiting for the refresh. The data is refreshed with the loader
+// given WithLoader or registered by SetLoader. Zero means that there is no refresh-ahead, and a
+// fraction which is negative or not less than one returns ErrInvalidConfig.
+func WithRefreshAhead(fraction float64) Option {
+	return func(o *Config) {
+		o.RefreshAhead = fraction
+	}
+}
+
+// WithStaleWhileRevalidate keeps the expired data for the given window. If Get
+// is called during the window, it returns the expired value and refreshes it
+// in the background with the loader given WithLoader or registered by
+// SetLoader. The other methods
+// treat the data as expired. If the window is zero or there is no loader, the
+// expired data is not returned. A negative window returns ErrInvalidConfig.
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	return func(o *Config) {
+		o.StaleWindow = window
+	}
+}
+
+// WithDefaultTTL sets the expiration duration of the data added with zero
+// duration, so the callers do not need to repeat it. Zero and NoExpiration
+// mean that there is no default TTL, and the data added with zero duration
+// never expires. A negative TTL returns ErrInvalidConfig.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(o *Config) {
+		o.DefaultTTL = ttl
+	}
+}
+
+// WithTTLJitter changes every TTL randomly by up to the given fraction in both
+// directions, so the data added together does not expire together. For
+// example, 0.1 spreads a one minute TTL between 54 and 66 seconds. Zero means
+// that there is no jitter, and a fraction which is negative or not less than
+// one returns ErrInvalidConfig.
+func WithTTLJitter(fraction float64) Option {
+	return func(o *Config) {
+		o.TTLJitter = fraction
+	}
+}
+
+// WithClock makes the cache read the current time from clock instead of the
+// system time. Every expiration is computed and checked with it, which makes
+// the tests of the expiration deterministic. If clock is nil, SystemClock is
+// used. The background cleanup enabled WithCleanupInterval still runs in real
+// time.
+func WithClock(clock Clock) Option {
+	return func(o *Config) {
+		o.Clock = clock
+	}
+}
+
+// WithSlidingExpiration makes Get push the expiration of the data forward by
+// the TTL that the data is added with, so the data expires only if it is not
+// read for the TTL. The data added without expiration is not affected.
+func WithSlidingExpiration() Option {
+	return func(o *Config) {
+		o.SlidingExpiration = true
+	}
+}
+
+// WithOnEvict registers fn as the eviction callback when the cache is created,
+// like OnEvict. The key and the value types of fn must be the types of the
+// cache, otherwise the constructor returns ErrInvalidConfig. The data replayed
+// from the write-ahead log is not reported to fn.
+func WithOnEvict[K comparable, V any](fn func(key K, val V, reason EvictReason)) Option {
+	return func(o *Config) {
+		o.OnEvict = fn
+	}
+}
+
+// WithWeigher registers fn to compute the cost of the data when the cache is
+// created, like SetWeigher, so the costs are computed once as the data is
+// added. The key and the value types of fn must be the types of the cache,
+// otherwise the constructor returns ErrInvalidConfig.
+func WithWeigher[K comparable, V any](fn func(key K, val V) int64) Option {
+	return func(o *Config) {
+		o.Weigher = fn
+	}
+}
+
+// WithLoader registers the loader that refreshes the data in the background
+// when the cache is created, like SetLoader. The key and the value types of
+// loader must be the types of the cache, otherwise the constructor returns
+// ErrInvalidConfig.
+func WithLoader[K comparable, V any](loader func(key K) (V, time.Duration, error)) Option {
+	return func(o *Config) {
+		o.Loader = loader
+	}
+}
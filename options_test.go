@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{
+			name:    "returns error without capacity",
+			opts:    nil,
+			wantErr: ErrZeroCapacity,
+		},
+		{
+			name:    "returns error for negative capacity",
+			opts:    []Option{WithCapacity(-1)},
+			wantErr: ErrNegativeCapacity,
+		},
+		{
+			name:    "returns error for unknown eviction policy",
+			opts:    []Option{WithCapacity(5), WithPolicy(Policy(100))},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for unknown sync policy",
+			opts:    []Option{WithCapacity(5), WithSyncPolicy(SyncPolicy(-1))},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for negative maximum cost",
+			opts:    []Option{WithCapacity(5), WithMaxCost(-1)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for negative negative TTL",
+			opts:    []Option{WithCapacity(5), WithNegativeTTL(-time.Second)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for refresh-ahead fraction over one",
+			opts:    []Option{WithCapacity(5), WithRefreshAhead(1.5)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for negative stale window",
+			opts:    []Option{WithCapacity(5), WithStaleWhileRevalidate(-time.Second)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for negative default TTL",
+			opts:    []Option{WithCapacity(5), WithDefaultTTL(-time.Second)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for TTL jitter over one",
+			opts:    []Option{WithCapacity(5), WithTTLJitter(2)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for negative cleanup interval",
+			opts:    []Option{WithCapacity(5), WithCleanupInterval(-time.Second)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for negative sync interval",
+			opts:    []Option{WithCapacity(5), WithSyncInterval(-time.Second)},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for eviction callback of other types",
+			opts:    []Option{WithCapacity(5), WithOnEvict(func(key string, val int, reason EvictReason) {})},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for weigher of other types",
+			opts:    []Option{WithCapacity(5), WithWeigher(func(key string, val int) int64 { return 1 })},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "returns error for loader of other types",
+			opts:    []Option{WithCapacity(5), WithLoader(func(key string) (int, time.Duration, error) { return 0, 0, nil })},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "validates before opening the log",
+			opts:    []Option{WithCapacity(5), WithWAL(filepath.Join("nonexistent", "cache.wal")), WithPolicy(Policy(100))},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name: "creates cache with options",
+			opts: []Option{WithCapacity(5), WithPolicy(PolicyLFU), WithDefaultTTL(time.Hour)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewWithOptions(tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error, got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && c.Cap() != 5 {
+				t.Errorf("unexpected capacity, got %v, want %v", c.Cap(), 5)
+			}
+		})
+	}
+}
+
+func TestNew_CapacityOverridesOption(t *testing.T) {
+	c, err := New(3, WithCapacity(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Cap() != 3 {
+		t.Errorf("unexpected capacity, got %v, want %v", c.Cap(), 3)
+	}
+}
+
+func TestCache_Config(t *testing.T) {
+	c, err := NewWithOptions(WithCapacity(5), WithMaxCost(100), WithSlidingExpiration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := c.Config()
+	want := Config{
+		Capacity:          5,
+		MaxCost:           100,
+		Codec:             GobCodec,
+		SyncInterval:      defaultSyncInterval,
+		SlidingExpiration: true,
+		Clock:             SystemClock,
+	}
+	if cfg != want {
+		t.Errorf("unexpected config, got %+v, want %+v", cfg, want)
+	}
+
+	c.Resize(3)
+	if _, err := c.ResizeCost(50); err != nil {
+		t.Fatal(err)
+	}
+	cfg = c.Config()
+	if cfg.Capacity != 3 || cfg.MaxCost != 50 {
+		t.Errorf("unexpected capacity and maximum cost, got %v and %v, want 3 and 50", cfg.Capacity, cfg.MaxCost)
+	}
+}
+
+func TestNewTyped_CallbackOptions(t *testing.T) {
+	var evicted []string
+	c, err := NewTyped[string, string](2,
+		WithOnEvict(func(key, val string, reason EvictReason) {
+			evicted = append(evicted, key)
+		}),
+		WithWeigher(func(key, val string) int64 {
+			return int64(len(val))
+		}),
+		WithLoader(func(key string) (string, time.Duration, error) {
+			return key, time.Hour, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := c.Config()
+	if cfg.OnEvict == nil || cfg.Weigher == nil || cfg.Loader == nil {
+		t.Errorf("unexpected callbacks in config, got %+v", cfg)
+	}
+
+	_ = c.Add("a", "123", 0)
+	if c.Cost() != 3 {
+		t.Errorf("unexpected cost, got %v, want %v", c.Cost(), 3)
+	}
+	_ = c.Add("b", "1", 0)
+	_ = c.Add("c", "1", 0)
+	if want := []string{"a"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("unexpected evictions, got %v, want %v", evicted, want)
+	}
+
+	c.OnEvict(nil)
+	c.SetWeigher(nil)
+	c.SetLoader(nil)
+	cfg = c.Config()
+	if cfg.OnEvict != nil || cfg.Weigher != nil || cfg.Loader != nil {
+		t.Errorf("unexpected callbacks in config after removing them, got %+v", cfg)
+	}
+}
+
+func TestCache_ResizeCostNegative(t *testing.T) {
+	c, err := NewTyped[string, string](5, WithMaxCost(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ResizeCost(-1); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrInvalidConfig)
+	}
+	if c.MaxCost() != 10 {
+		t.Errorf("unexpected max cost, got %v, want %v", c.MaxCost(), 10)
+	}
+
+	s, err := NewTypedSharded[string, string](2, 10, WithMaxCost(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResizeCost(-1); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrInvalidConfig)
+	}
+	if s.MaxCost() != 10 {
+		t.Errorf("unexpected max cost of sharded cache, got %v, want %v", s.MaxCost(), 10)
+	}
+}
+
+func TestShardedCache_Config(t *testing.T) {
+	s, err := NewSharded(4, 10, WithMaxCost(8), WithPolicy(PolicyARC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := s.Config()
+	if cfg.Capacity != 10 || cfg.MaxCost != 8 || cfg.Policy != PolicyARC {
+		t.Errorf("unexpected config, got %+v", cfg)
+	}
+	if _, err := NewSharded(2, 10, WithPolicy(Policy(100))); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrInvalidConfig)
+	}
+}
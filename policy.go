@@ -0,0 +1,264 @@
+package cache
+
+// Policy is the eviction policy of the cache. It decides which data is
+// evicted when the cache is full and which data is removed by RemoveOldest.
+type Policy int
+
+const (
+	// PolicyLRU evicts the least recently used data. It is the default policy.
+	PolicyLRU Policy = iota
+
+	// PolicyLFU evicts the least frequently used data. Ties are broken by
+	// evicting the least recently used one.
+	PolicyLFU
+
+	// PolicyFIFO evicts the oldest added data regardless of the accesses.
+	PolicyFIFO
+
+	// PolicyARC is the Adaptive Replacement Cache. It balances between
+	// recency and frequency by remembering the recently evicted keys.
+	PolicyARC
+
+	// Policy2Q is the full 2Q algorithm. New data stays in a FIFO queue and is
+	// promoted to an LRU queue only if it is added again shortly after its
+	// eviction, so scans do not flush the frequently used data.
+	Policy2Q
+
+	// PolicyTinyLFU is W-TinyLFU. New data enters a small LRU window and is
+	// admitted to the main segmented LRU only if it is estimated to be more
+	// frequently used than the data it would replace.
+	PolicyTinyLFU
+)
+
+// String returns the name of the policy.
+func (p Policy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "LRU"
+	case PolicyLFU:
+		return "LFU"
+	case PolicyFIFO:
+		return "FIFO"
+	case PolicyARC:
+		return "ARC"
+	case Policy2Q:
+		return "2Q"
+	case PolicyTinyLFU:
+		return "W-TinyLFU"
+	default:
+		return "unknown"
+	}
+}
+
+// policy tracks the keys of the cache to choose the eviction victims. The
+// cache calls its methods while holding the write lock.
+type policy[K comparable] interface {
+	// add records a key which is added to the cache.
+	add(key K)
+
+	// access records a read or an update of a key in the cache.
+	access(key K)
+
+	// remove forgets a key which is removed from the cache for the given
+	// reason.
+	remove(key K, reason EvictReason)
+
+	// victim returns the key which should be evicted next. It is only called
+	// when the cache is not empty.
+	victim() K
+
+	// resize changes the capacity the policy is tuned for.
+	resize(cap int)
+
+	// clear forgets all keys.
+	clear()
+}
+
+// newPolicy creates the policy of the given kind for the given capacity. It
+// returns nil for PolicyLRU, since the cache list is already in LRU order.
+func newPolicy[K comparable](p Policy, cap int) policy[K] {
+	switch p {
+	case PolicyLFU:
+		return newLFU[K]()
+	case PolicyFIFO:
+		return &fifo[K]{keys: newKeyList[K](&keyPool[K]{})}
+	case PolicyARC:
+		return newARC[K](cap)
+	case Policy2Q:
+		return newTwoQueue[K](cap)
+	case PolicyTinyLFU:
+		return newTinyLFU[K](cap)
+	default:
+		return nil
+	}
+}
+
+// evicted reports whether the reason means that the data is evicted by the
+// policy rather than removed by the user.
+func (r EvictReason) evicted() bool {
+	return r == ReasonCapacity || r == ReasonResized
+}
+
+// keyNode is a node of a keyList.
+type keyNode[K comparable] struct {
+	key        K
+	prev, next *keyNode[K]
+}
+
+// keyPool keeps the nodes removed from the keyLists of a policy, so that
+// moving a key between the lists and replacing the evicted keys with new ones
+// do not allocate. The pool holds at most as many nodes as the lists held
+// together at their peak.
+type keyPool[K comparable] struct {
+	free *keyNode[K]
+}
+
+// get returns a node holding key, reusing a free node if there is one.
+func (p *keyPool[K]) get(key K) *keyNode[K] {
+	n := p.free
+	if n != nil {
+		p.free = n.next
+		n.next = nil
+	} else {
+		n = &keyNode[K]{}
+	}
+	n.key = key
+	return n
+}
+
+// put returns n to the pool. The node must not be used after it is put.
+func (p *keyPool[K]) put(n *keyNode[K]) {
+	*n = keyNode[K]{next: p.free}
+	p.free = n
+}
+
+// keyList is a doubly-linked circular list of keys with a sentinel root and
+// an index for constant time lookups. It is the building block of the
+// policies. The nodes are taken from and returned to the pool of the policy.
+type keyList[K comparable] struct {
+	root  keyNode[K]
+	len   int
+	items map[K]*keyNode[K]
+	pool  *keyPool[K]
+}
+
+// newKeyList creates an empty keyList which takes its nodes from pool.
+func newKeyList[K comparable](pool *keyPool[K]) *keyList[K] {
+	l := &keyList[K]{items: make(map[K]*keyNode[K]), pool: pool}
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	return l
+}
+
+// contains reports whether the key is in the list.
+func (l *keyList[K]) contains(key K) bool {
+	_, ok := l.items[key]
+	return ok
+}
+
+// pushFront adds the key to the front of the list.
+func (l *keyList[K]) pushFront(key K) {
+	n := l.pool.get(key)
+	l.link(n)
+	l.items[key] = n
+	l.len++
+}
+
+// moveToFront moves the key to the front of the list.
+func (l *keyList[K]) moveToFront(key K) {
+	n := l.items[key]
+	if l.root.next == n {
+		return
+	}
+	l.unlink(n)
+	l.link(n)
+}
+
+// remove removes the key from the list. It reports whether the key was in the
+// list.
+func (l *keyList[K]) remove(key K) bool {
+	n, ok := l.items[key]
+	if !ok {
+		return false
+	}
+	l.unlink(n)
+	delete(l.items, key)
+	l.len--
+	l.pool.put(n)
+	return true
+}
+
+// front returns the key at the front of the list.
+func (l *keyList[K]) front() K {
+	return l.root.next.key
+}
+
+// back returns the key at the back of the list.
+func (l *keyList[K]) back() K {
+	return l.root.prev.key
+}
+
+// popBack removes and returns the key at the back of the list.
+func (l *keyList[K]) popBack() K {
+	key := l.back()
+	l.remove(key)
+	return key
+}
+
+// clear removes all keys from the list. The nodes are returned to the pool
+// and the index keeps its memory, so refilling the list does not allocate.
+func (l *keyList[K]) clear() {
+	for n := l.root.next; n != &l.root; {
+		next := n.next
+		l.pool.put(n)
+		n = next
+	}
+	for key := range l.items {
+		delete(l.items, key)
+	}
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	l.len = 0
+}
+
+// link inserts n after the root.
+func (l *keyList[K]) link(n *keyNode[K]) {
+	n.prev = &l.root
+	n.next = l.root.next
+	n.prev.next = n
+	n.next.prev = n
+}
+
+// unlink removes n from its neighbors.
+func (l *keyList[K]) unlink(n *keyNode[K]) {
+	n.prev.next = n.next
+	n.next.prev = n.prev
+}
+
+// fifo evicts the keys in the order they are added.
+type fifo[K comparable] struct {
+	keys *keyList[K]
+}
+
+func (f *fifo[K]) add(key K)                        { f.keys.pushFront(key) }
+func (f *fifo[K]) access(K)                         {}
+func (f *fifo[K]) remove(key K, reason EvictReason) { f.keys.remove(key) }
+func (f *fifo[K]) victim() K                        { return f.keys.back() }
+func (f *fifo[K]) resize(int)                       {}
+func (f *fifo[K]) clear()                           { f.keys.clear() }
+
+// minInt returns the smaller of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+const (
+	// traceLen is the number of accesses of the synthetic traces.
+	traceLen = 100_000
+
+	// traceKeys is the number of distinct keys of the Zipf distribution.
+	traceKeys = 10_000
+
+	// traceCap is the cache capacity used to replay the traces.
+	traceCap = 1_000
+)
+
+// zipfTrace returns keys drawn from a Zipf distribution, where a few keys are
+// accessed much more frequently than the others.
+func zipfTrace(n int) []int {
+	rnd := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(rnd, 1.1, 1, traceKeys-1)
+	keys := make([]int, n)
+	for i := range keys {
+		keys[i] = int(z.Uint64())
+	}
+	return keys
+}
+
+// scanTrace returns a Zipf trace which is interrupted by sequential scans of
+// keys that are accessed only once.
+func scanTrace(n int) []int {
+	keys := zipfTrace(n)
+	next := traceKeys
+	for i := 0; i+traceCap < len(keys); i += 5 * traceCap {
+		for j := 0; j < traceCap; j++ {
+			keys[i+j] = next
+			next++
+		}
+	}
+	return keys
+}
+
+func BenchmarkPolicy_HitRate(b *testing.B) {
+	traces := []struct {
+		name string
+		keys []int
+	}{
+		{name: "zipf", keys: zipfTrace(traceLen)},
+		{name: "scan", keys: scanTrace(traceLen)},
+	}
+	policies := []Policy{PolicyLRU, PolicyLFU, PolicyFIFO, PolicyARC, Policy2Q, PolicyTinyLFU}
+	for _, tr := range traces {
+		for _, p := range policies {
+			b.Run(fmt.Sprintf("trace=%s/policy=%s", tr.name, p), func(b *testing.B) {
+				var hits, total int
+				for i := 0; i < b.N; i++ {
+					c, _ := NewTyped[int, int](traceCap, WithPolicy(p))
+					for _, key := range tr.keys {
+						if _, found := c.Get(key); found {
+							hits++
+						} else {
+							_ = c.Add(key, key, 0)
+						}
+						total++
+					}
+				}
+				b.ReportMetric(100*float64(hits)/float64(total), "hit%")
+			})
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// runOps runs the operations on the cache. An operation is "add <key>" or
+// "get <key>". It is a helper function to prevent code duplication.
+func runOps(t *testing.T, c *Cache, ops []string) {
+	t.Helper()
+	for _, op := range ops {
+		var name, key string
+		if _, err := fmt.Sscan(op, &name, &key); err != nil {
+			t.Fatalf("invalid operation %q", op)
+		}
+		switch name {
+		case "add":
+			if err := c.Add(key, key, 0); err != nil {
+				t.Fatalf("unexpected error, got %v", err)
+			}
+		case "get":
+			c.Get(key)
+		}
+	}
+}
+
+func TestCache_Policy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		capacity    int
+		ops         []string
+		wantEvicted []any
+		wantKeys    []any
+	}{
+		{
+			name:        "LRU evicts least recently used",
+			policy:      PolicyLRU,
+			capacity:    3,
+			ops:         []string{"add a", "add b", "add c", "get a", "add d"},
+			wantEvicted: []any{"b"},
+			wantKeys:    []any{"a", "c", "d"},
+		},
+		{
+			name:        "FIFO evicts first added regardless of access",
+			policy:      PolicyFIFO,
+			capacity:    3,
+			ops:         []string{"add a", "add b", "add c", "get a", "add d"},
+			wantEvicted: []any{"a"},
+			wantKeys:    []any{"b", "c", "d"},
+		},
+		{
+			name:        "LFU evicts least frequently used",
+			policy:      PolicyLFU,
+			capacity:    3,
+			ops:         []string{"add a", "add b", "add c", "get a", "get a", "get b", "get c", "get c", "add d", "add e"},
+			wantEvicted: []any{"b", "d"},
+			wantKeys:    []any{"a", "c", "e"},
+		},
+		{
+			name:        "ARC evicts from recency list and promotes ghost hits",
+			policy:      PolicyARC,
+			capacity:    2,
+			ops:         []string{"add a", "add b", "get a", "add c", "add b"},
+			wantEvicted: []any{"b", "c"},
+			wantKeys:    []any{"a", "b"},
+		},
+		{
+			name:        "2Q promotes keys added again shortly after eviction",
+			policy:      Policy2Q,
+			capacity:    4,
+			ops:         []string{"add a", "add b", "add c", "add d", "add e", "add a", "add x", "add y", "add z"},
+			wantEvicted: []any{"a", "b", "c", "d", "e"},
+			wantKeys:    []any{"a", "x", "y", "z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.capacity, WithPolicy(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var evicted []any
+			c.OnEvict(func(key, val any, reason EvictReason) {
+				evicted = append(evicted, key)
+			})
+			runOps(t, c, tt.ops)
+			if !reflect.DeepEqual(evicted, tt.wantEvicted) {
+				t.Errorf("unexpected evicted keys, got %v, want %v", evicted, tt.wantEvicted)
+			}
+			keys := c.Keys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].(string) < keys[j].(string) })
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("unexpected keys, got %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestCache_PolicyScanResistance(t *testing.T) {
+	tests := []struct {
+		policy   Policy
+		wantKeep bool
+	}{
+		{policy: PolicyLRU, wantKeep: false},
+		{policy: PolicyLFU, wantKeep: true},
+		{policy: PolicyARC, wantKeep: true},
+		{policy: Policy2Q, wantKeep: true},
+		{policy: PolicyTinyLFU, wantKeep: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			c, err := New(10, WithPolicy(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var hot []string
+			for i := 0; i < 5; i++ {
+				hot = append(hot, fmt.Sprint("hot", i))
+			}
+			// The hot keys are used repeatedly while a few one-off keys pass
+			// through the cache.
+			for round := 0; round < 20; round++ {
+				for _, key := range hot {
+					if _, found := c.Get(key); !found {
+						_ = c.Add(key, key, 0)
+					}
+				}
+				for i := 0; i < 3; i++ {
+					_ = c.Add(fmt.Sprint("warm", round, i), i, 0)
+				}
+			}
+			for i := 0; i < 100; i++ {
+				_ = c.Add(fmt.Sprint("scan", i), i, 0)
+			}
+
+			kept := 0
+			for _, key := range hot {
+				if c.Contains(key) {
+					kept++
+				}
+			}
+			if tt.wantKeep && kept != len(hot) {
+				t.Errorf("expected hot keys to survive the scan, kept %v of %v", kept, len(hot))
+			}
+			if !tt.wantKeep && kept != 0 {
+				t.Errorf("expected hot keys to be flushed by the scan, kept %v of %v", kept, len(hot))
+			}
+		})
+	}
+}
+
+func TestCache_TinyLFUResizeKeepsFrequencies(t *testing.T) {
+	c, err := New(10, WithPolicy(PolicyTinyLFU))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 5; i++ {
+			key := fmt.Sprint("hot", i)
+			if _, found := c.Get(key); !found {
+				_ = c.Add(key, i, 0)
+			}
+		}
+	}
+	c.Resize(40)
+	c.Resize(20)
+	for i := 0; i < 100; i++ {
+		_ = c.Add(fmt.Sprint("scan", i), i, 0)
+	}
+	for i := 0; i < 5; i++ {
+		if key := fmt.Sprint("hot", i); !c.Contains(key) {
+			t.Errorf("%s is evicted by the scan after resizing", key)
+		}
+	}
+}
+
+func TestSketch_Resize(t *testing.T) {
+	s := newSketch(16)
+	counts := make(map[uint64]uint8)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		h := rnd.Uint64()
+		for n := rnd.Intn(3); n >= 0; n-- {
+			s.increment(h)
+		}
+		counts[h] = 0
+	}
+	for h := range counts {
+		counts[h] = s.estimate(h)
+	}
+
+	rows := s.rows
+	s.resize(10)
+	if &s.rows[0][0] != &rows[0][0] {
+		t.Error("sketch is rebuilt for the same width")
+	}
+	for _, cap := range []int{100, 1000, 20, 16} {
+		s.resize(cap)
+		if len(s.rows[0]) != sketchWidth(cap) {
+			t.Fatalf("unexpected width, got %v, want %v", len(s.rows[0]), sketchWidth(cap))
+		}
+		for h, want := range counts {
+			if got := s.estimate(h); got < want {
+				t.Errorf("estimate of %x dropped to %v, want at least %v after resizing to %v", h, got, want, cap)
+			}
+		}
+	}
+}
+
+func TestCache_PolicyInvariants(t *testing.T) {
+	policies := []Policy{PolicyLRU, PolicyLFU, PolicyFIFO, PolicyARC, Policy2Q, PolicyTinyLFU}
+	for _, p := range policies {
+		t.Run(p.String(), func(t *testing.T) {
+			c, err := New(16, WithPolicy(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rnd := rand.New(rand.NewSource(1))
+			for i := 0; i < 5000; i++ {
+				key := rnd.Intn(64)
+				switch rnd.Intn(8) {
+				case 0, 1, 2:
+					_ = c.Add(key, i, 0)
+				case 3, 4:
+					c.Get(key)
+				case 5:
+					_ = c.Remove(key)
+				case 6:
+					_, _ = c.UpdateVal(key, i)
+				case 7:
+					if i%500 == 7 {
+						c.Resize(8 + rnd.Intn(16))
+					}
+				}
+				if c.Len() > c.Cap() {
+					t.Fatalf("length %v exceeds capacity %v", c.Len(), c.Cap())
+				}
+			}
+			checkInvariants(t, c)
+
+			// The policy must return every key exactly once as victim.
+			want := len(c.Keys())
+			seen := make(map[any]bool)
+			for i := 0; i < want; i++ {
+				key, _, ok := c.RemoveOldest()
+				if !ok || seen[key] {
+					t.Fatalf("unexpected RemoveOldest() result, key %v, ok %v", key, ok)
+				}
+				seen[key] = true
+			}
+			if c.Len() != 0 {
+				t.Errorf("expected cache to be empty, got length %v", c.Len())
+			}
+		})
+	}
+}
+
+func TestPolicy_String(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   string
+	}{
+		{PolicyLRU, "LRU"},
+		{PolicyLFU, "LFU"},
+		{PolicyFIFO, "FIFO"},
+		{PolicyARC, "ARC"},
+		{Policy2Q, "2Q"},
+		{PolicyTinyLFU, "W-TinyLFU"},
+		{Policy(-1), "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Errorf("Policy(%d).String() = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
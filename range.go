@@ -0,0 +1,114 @@
+package cache
+
+import "time"
+
+// Range calls fn for the key, the value and the expiration of every unexpired
+// data from the most recently used to the least recently used one, until fn
+// returns false. The expiration is the zero time if the data never expires.
+// Range does not change the access order or the statistics, so it can run
+// concurrently with other readers. The read lock is held while fn runs, so fn
+// must not call the methods of the cache.
+func (c *TypedCache[K, V]) Range(fn func(key K, val V, exp time.Time) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		if !e.item.expired(now) && !fn(e.item.Key, e.item.Val, e.item.Expiration) {
+			return
+		}
+	}
+}
+
+// RangeBackward is like Range, but it calls fn from the least recently used
+// data to the most recently used one.
+func (c *TypedCache[K, V]) RangeBackward(fn func(key K, val V, exp time.Time) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	for e := c.lst.back(); e != nil; e = c.lst.prev(e) {
+		if !e.item.expired(now) && !fn(e.item.Key, e.item.Val, e.item.Expiration) {
+			return
+		}
+	}
+}
+
+// Items returns a copy of the unexpired data from the most recently used to
+// the least recently used one. Unlike Range, the cache can be changed while
+// the items are used. It does not change the access order or the statistics.
+func (c *TypedCache[K, V]) Items() []TypedItem[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	items := make([]TypedItem[K, V], 0, c.lst.len)
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		if !e.item.expired(now) {
+			items = append(items, e.item)
+		}
+	}
+	return items
+}
+
+// Values returns the values of the unexpired data in the order of Items. It
+// does not change the access order or the statistics.
+func (c *TypedCache[K, V]) Values() []V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	vals := make([]V, 0, c.lst.len)
+	for e := c.lst.front(); e != nil; e = c.lst.next(e) {
+		if !e.item.expired(now) {
+			vals = append(vals, e.item.Val)
+		}
+	}
+	return vals
+}
+
+// Range calls fn for the unexpired data of every shard like
+// TypedCache.Range, until fn returns false. The data is ordered by recency
+// within each shard, but there is no order across the shards. The read lock of
+// the current shard is held while fn runs, so fn must not call the methods of
+// the cache.
+func (s *TypedShardedCache[K, V]) Range(fn func(key K, val V, exp time.Time) bool) {
+	next := true
+	for _, c := range s.shards {
+		c.Range(func(key K, val V, exp time.Time) bool {
+			next = fn(key, val, exp)
+			return next
+		})
+		if !next {
+			return
+		}
+	}
+}
+
+// RangeBackward is like Range, but it calls fn from the least recently used
+// data to the most recently used one within each shard.
+func (s *TypedShardedCache[K, V]) RangeBackward(fn func(key K, val V, exp time.Time) bool) {
+	next := true
+	for i := len(s.shards) - 1; i >= 0 && next; i-- {
+		s.shards[i].RangeBackward(func(key K, val V, exp time.Time) bool {
+			next = fn(key, val, exp)
+			return next
+		})
+	}
+}
+
+// Items returns a copy of the unexpired data of all shards in the order of
+// Range.
+func (s *TypedShardedCache[K, V]) Items() []TypedItem[K, V] {
+	var items []TypedItem[K, V]
+	for _, c := range s.shards {
+		items = append(items, c.Items()...)
+	}
+	return items
+}
+
+// Values returns the values of the unexpired data of all shards in the order
+// of Range.
+func (s *TypedShardedCache[K, V]) Values() []V {
+	var vals []V
+	for _, c := range s.shards {
+		vals = append(vals, c.Values()...)
+	}
+	return vals
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+// createRangeCache creates a cache holding the keys 1 to 4, where 1 is the
+// least recently used and 4 is the most recently used data, and 5 is expired.
+func createRangeCache(t *testing.T) (*TypedCache[int, string], *cachetest.Clock) {
+	t.Helper()
+	clock := cachetest.NewClock(time.Now())
+	c, err := NewTyped[int, string](10, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Add(5, "five", time.Second)
+	for i, val := range []string{"one", "two", "three", "four"} {
+		_ = c.Add(i+1, val, time.Duration(i)*time.Minute)
+	}
+	clock.Advance(2 * time.Second)
+	return c, clock
+}
+
+func TestCache_Range(t *testing.T) {
+	c, clock := createRangeCache(t)
+	c.ResetStats()
+
+	var keys []int
+	var exps []time.Time
+	c.Range(func(key int, val string, exp time.Time) bool {
+		keys = append(keys, key)
+		exps = append(exps, exp)
+		return true
+	})
+	if want := []int{4, 3, 2, 1}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("unexpected keys, got %v, want %v", keys, want)
+	}
+	if !exps[3].IsZero() {
+		t.Errorf("data without expiration has expiration %v", exps[3])
+	}
+	if got := exps[0].Sub(clock.Now()); got != 3*time.Minute-2*time.Second {
+		t.Errorf("unexpected expiration, got %v from now", got)
+	}
+
+	keys = nil
+	c.RangeBackward(func(key int, val string, exp time.Time) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if want := []int{1, 2}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("unexpected keys, got %v, want %v", keys, want)
+	}
+
+	// Ranging does not change the recency or the statistics.
+	if k, _, _ := c.RemoveOldest(); k != 5 {
+		t.Errorf("unexpected oldest key, got %v, want %v", k, 5)
+	}
+	if k, _, _ := c.RemoveOldest(); k != 1 {
+		t.Errorf("unexpected oldest key, got %v, want %v", k, 1)
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestCache_ItemsAndValues(t *testing.T) {
+	c, _ := createRangeCache(t)
+
+	items := c.Items()
+	var keys []int
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	if want := []int{4, 3, 2, 1}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("unexpected keys, got %v, want %v", keys, want)
+	}
+	if want := []string{"four", "three", "two", "one"}; !reflect.DeepEqual(c.Values(), want) {
+		t.Errorf("unexpected values, got %v, want %v", c.Values(), want)
+	}
+
+	// The items are copies, so the cache can be changed while they are used.
+	for _, item := range items {
+		_ = c.Remove(item.Key)
+	}
+	if c.Len() != 1 {
+		t.Errorf("unexpected length, got %v, want %v", c.Len(), 1)
+	}
+
+	empty, _ := NewTyped[int, string](5)
+	if len(empty.Items()) != 0 || len(empty.Values()) != 0 {
+		t.Error("empty cache has items")
+	}
+}
+
+func TestShardedCache_Range(t *testing.T) {
+	c := createShardedCache(t, 4, 100)
+	for i := 0; i < 50; i++ {
+		_ = c.Add(i, i*10, 0)
+	}
+
+	var keys []int
+	c.Range(func(key, val any, exp time.Time) bool {
+		if val != key.(int)*10 {
+			t.Errorf("unexpected value of %v, got %v", key, val)
+		}
+		keys = append(keys, key.(int))
+		return true
+	})
+	sort.Ints(keys)
+	if len(keys) != 50 || keys[0] != 0 || keys[49] != 49 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	var n int
+	c.RangeBackward(func(key, val any, exp time.Time) bool {
+		n++
+		return n < 10
+	})
+	if n != 10 {
+		t.Errorf("range is not stopped, got %v calls, want %v", n, 10)
+	}
+	n = 0
+	c.Range(func(key, val any, exp time.Time) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("range is not stopped, got %v calls, want %v", n, 1)
+	}
+
+	if len(c.Items()) != 50 || len(c.Values()) != 50 {
+		t.Errorf("unexpected item count, got %v and %v", len(c.Items()), len(c.Values()))
+	}
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// refresher reloads the data in the background with the loader registered by
+// SetLoader.
+type refresher[K comparable, V any] struct {
+	// loader loads the fresh value of a key. Refreshing is disabled if it is
+	// nil.
+	loader func(key K) (V, time.Duration, error)
+
+	// ahead is the fraction of the TTL after which Get starts a refresh.
+	// Zero means that there is no refresh-ahead.
+	ahead float64
+
+	// staleWindow is the duration after the expiration during which Get
+	// returns the expired value while it is refreshed.
+	staleWindow time.Duration
+
+	// pending holds the keys that are being refreshed. It is protected by
+	// the cache mutex.
+	pending map[K]struct{}
+
+	// wg waits for the refresh goroutines.
+	wg sync.WaitGroup
+}
+
+// SetLoader registers the loader that refreshes the data in the background.
+// The loader returns the fresh value of the key and its expiration duration.
+// It is used by the refresh-ahead mode enabled WithRefreshAhead and by the
+// stale-while-revalidate mode enabled WithStaleWhileRevalidate. If the loader
+// returns an error or panics, the existing data is kept. Passing nil disables
+// refreshing.
+func (c *TypedCache[K, V]) SetLoader(loader func(key K) (V, time.Duration, error)) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.refresher.loader = loader
+}
+
+// stale reports whether the expired item can still be returned while it is
+// refreshed.
+func (c *TypedCache[K, V]) stale(item TypedItem[K, V], now time.Time) bool {
+	r := &c.refresher
+	return r.loader != nil && r.staleWindow > 0 && !now.After(item.Expiration.Add(r.staleWindow))
+}
+
+// refreshDue reports whether the item has passed the refresh-ahead fraction of
+// its TTL.
+func (c *TypedCache[K, V]) refreshDue(item TypedItem[K, V], now time.Time) bool {
+	r := &c.refresher
+	if r.loader == nil || r.ahead == 0 || item.ttl <= 0 {
+		return false
+	}
+	left := time.Duration(float64(item.ttl) * (1 - r.ahead))
+	return !now.Before(item.Expiration.Add(-left))
+}
+
+// refresh starts reloading the key in the background unless it is already
+// being reloaded. The fresh value replaces the existing data only if the key
+// is still in the cache. If the loader fails or panics, the existing data is
+// kept. The cache mutex must be held.
+func (c *TypedCache[K, V]) refresh(key K) {
+	r := &c.refresher
+	if _, found := r.pending[key]; found {
+		return
+	}
+	if r.pending == nil {
+		r.pending = make(map[K]struct{})
+	}
+	r.pending[key] = struct{}{}
+	c.stats.refreshes.Add(1)
+	loader := r.loader
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		val, ttl, err := tryLoad(loader, key)
+		c.mu.Lock()
+		defer c.unlock()
+		delete(r.pending, key)
+		if err != nil {
+			return
+		}
+		if _, found := c.get(key); found {
+			_ = c.set(c.newItem(key, val, ttl))
+		}
+	}()
+}
+
+// tryLoad calls loader and turns its panic into ErrLoaderPanic. The refresh
+// runs in the background, so there is no caller to propagate the panic to.
+func tryLoad[K comparable, V any](loader func(key K) (V, time.Duration, error), key K) (val V, ttl time.Duration, err error) {
+	defer func() {
+		if recover() != nil {
+			err = keyError(key, ErrLoaderPanic)
+		}
+	}()
+	return loader(key)
+}
+
+// retained reports whether the item should be kept in the cache. The expired
+// item is kept during the stale window, so it can be returned while it is
+// refreshed.
+func (c *TypedCache[K, V]) retained(item TypedItem[K, V], now time.Time) bool {
+	return !item.expired(now) || c.stale(item, now)
+}
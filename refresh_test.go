@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+// createRefreshCache creates a cache whose loader returns v with a counter
+// suffix and counts the loads. The cache reads the time from clock. It is a
+// helper function to prevent code duplication.
+func createRefreshCache(t *testing.T, loads *atomic.Int32, clock Clock, opts ...Option) *TypedCache[string, string] {
+	t.Helper()
+	c, err := NewTyped[string, string](5, append([]Option{WithClock(clock)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetLoader(func(key string) (string, time.Duration, error) {
+		n := loads.Add(1)
+		return v + string(rune('0'+n)), time.Hour, nil
+	})
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	var loads atomic.Int32
+	clock := cachetest.NewClock(time.Now())
+	c := createRefreshCache(t, &loads, clock, WithRefreshAhead(0.5))
+	_ = c.Add(k, v, 200*time.Millisecond)
+
+	if val, _ := c.Get(k); val != v {
+		t.Fatalf("val = %s, want %s", val, v)
+	}
+	c.refresher.wg.Wait()
+	if got := loads.Load(); got != 0 {
+		t.Fatalf("loads = %d, want no refresh before the fraction of TTL", got)
+	}
+
+	clock.Advance(110 * time.Millisecond)
+	if val, found := c.Get(k); !found || val != v {
+		t.Fatalf("Get = (%s, %v), want the current value (%s, true)", val, found, v)
+	}
+	c.refresher.wg.Wait()
+	if got := loads.Load(); got != 1 {
+		t.Errorf("loads = %d, want 1", got)
+	}
+	item, _ := c.peek(k)
+	if item.Val != v+"1" || item.ttl != time.Hour {
+		t.Errorf("item = %+v, want refreshed value with the loaded TTL", item)
+	}
+	if got := c.Stats().Refreshes; got != 1 {
+		t.Errorf("refreshes = %d, want 1", got)
+	}
+}
+
+func TestCache_RefreshOnce(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := NewTyped[string, string](5, WithRefreshAhead(0.1), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loads atomic.Int32
+	release := make(chan struct{})
+	c.SetLoader(func(key string) (string, time.Duration, error) {
+		loads.Add(1)
+		<-release
+		return v + v, time.Hour, nil
+	})
+	_ = c.Add(k, v, 100*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if val, _ := c.Get(k); val != v {
+				t.Errorf("val = %s, want %s", val, v)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+	c.Close()
+	if got := loads.Load(); got != 1 {
+		t.Errorf("loads = %d, want 1", got)
+	}
+	if val, _ := c.Peek(k); val != v+v {
+		t.Errorf("val = %s, want %s", val, v+v)
+	}
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	var loads atomic.Int32
+	clock := cachetest.NewClock(time.Now())
+	c := createRefreshCache(t, &loads, clock, WithStaleWhileRevalidate(time.Hour))
+	_ = c.Add(k, v, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+
+	if c.Contains(k) {
+		t.Error("Contains reports the expired data")
+	}
+	c.ClearExpiredData()
+	if c.Len() != 1 {
+		t.Fatalf("Len = %d, want the stale data to be kept", c.Len())
+	}
+
+	if val, found := c.Get(k); !found || val != v {
+		t.Fatalf("Get = (%s, %v), want the stale value (%s, true)", val, found, v)
+	}
+	c.refresher.wg.Wait()
+	if val, found := c.Get(k); !found || val != v+"1" {
+		t.Errorf("Get = (%s, %v), want the refreshed value (%s, true)", val, found, v+"1")
+	}
+	st := c.Stats()
+	if st.StaleHits != 1 || st.Refreshes != 1 || st.Hits != 2 {
+		t.Errorf("stats = %+v, want 1 stale hit, 1 refresh and 2 hits", st)
+	}
+}
+
+func TestCache_StaleWindowPassed(t *testing.T) {
+	var loads atomic.Int32
+	clock := cachetest.NewClock(time.Now())
+	c := createRefreshCache(t, &loads, clock, WithStaleWhileRevalidate(10*time.Millisecond))
+	_ = c.Add(k, v, 10*time.Millisecond)
+	clock.Advance(40 * time.Millisecond)
+
+	if _, found := c.Get(k); found {
+		t.Error("data is returned after the stale window")
+	}
+	c.refresher.wg.Wait()
+	if c.Len() != 0 || loads.Load() != 0 {
+		t.Errorf("Len = %d, loads = %d, want the data removed without a refresh", c.Len(), loads.Load())
+	}
+}
+
+func TestCache_StaleWithoutLoader(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := NewTyped[string, string](5, WithStaleWhileRevalidate(time.Hour), WithRefreshAhead(0.5), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Add(k, v, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if _, found := c.Get(k); found {
+		t.Error("expired data is returned without a loader")
+	}
+}
+
+func TestCache_RefreshFailure(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := NewTyped[string, string](5, WithStaleWhileRevalidate(time.Hour), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetLoader(func(key string) (string, time.Duration, error) {
+		return "", 0, errors.New("backend is down")
+	})
+	_ = c.Add(k, v, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if val, found := c.Get(k); !found || val != v {
+			t.Errorf("Get = (%s, %v), want the stale value (%s, true)", val, found, v)
+		}
+		c.refresher.wg.Wait()
+	}
+}
+
+func TestCache_RefreshRemovedKey(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := NewTyped[string, string](5, WithRefreshAhead(0.1), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	c.SetLoader(func(key string) (string, time.Duration, error) {
+		<-release
+		return v + v, 0, nil
+	})
+	_ = c.Add(k, v, 100*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	c.Get(k)
+	_ = c.Remove(k)
+	close(release)
+	c.refresher.wg.Wait()
+	if c.Contains(k) {
+		t.Error("refresh adds the removed key back")
+	}
+}
+
+func TestShardedCache_SetLoader(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	s, err := NewTypedSharded[string, string](2, 10, WithStaleWhileRevalidate(time.Hour), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetLoader(func(key string) (string, time.Duration, error) {
+		return key + key, 0, nil
+	})
+	_ = s.Add(k, v, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if val, _ := s.Get(k); val != v {
+		t.Errorf("val = %s, want the stale value %s", val, v)
+	}
+	s.Close()
+	if val, _ := s.Get(k); val != k+k {
+		t.Errorf("val = %s, want the refreshed value %s", val, k+k)
+	}
+	if st := s.Stats(); st.StaleHits != 1 || st.Refreshes != 1 {
+		t.Errorf("stats = %+v, want 1 stale hit and 1 refresh", st)
+	}
+}
+
+func TestCache_RefreshLoaderPanic(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	c, err := NewTyped[string, string](5, WithRefreshAhead(0.5), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loads atomic.Int32
+	c.SetLoader(func(key string) (string, time.Duration, error) {
+		loads.Add(1)
+		panic("loader failed")
+	})
+	_ = c.Add(k, v, 100*time.Millisecond)
+	clock.Advance(60 * time.Millisecond)
+
+	for i := 1; i <= 2; i++ {
+		if val, found := c.Get(k); !found || val != v {
+			t.Fatalf("Get = (%s, %v), want the existing value (%s, true)", val, found, v)
+		}
+		c.refresher.wg.Wait()
+		if got := loads.Load(); got != int32(i) {
+			t.Fatalf("loads = %d, want %d after the panicking refresh is cleared", got, i)
+		}
+	}
+	if item, _ := c.peek(k); item.Val != v || item.ttl != 100*time.Millisecond {
+		t.Errorf("item = %+v, want the existing data", item)
+	}
+	if len(c.refresher.pending) != 0 {
+		t.Errorf("pending refreshes = %v, want none", c.refresher.pending)
+	}
+}
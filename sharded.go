@@ -0,0 +1,451 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// walShardSeed is the key hash seed of the sharded caches with a write-ahead
+// log. The seed is fixed, so a key belongs to the same shard after a restart,
+// and every shard log is replayed into the shard of its keys.
+const walShardSeed = 0x9e3779b97f4a7c15
+
+// ShardedCache is a cache that spreads its data across multiple independent
+// LRU shards to reduce lock contention. It accepts any comparable data as key
+// and any data as value. Use TypedShardedCache for compile-time type safety.
+type ShardedCache = TypedShardedCache[any, any]
+
+// TypedShardedCache is a cache that spreads the data of type V with keys of
+// type K across multiple TypedCache shards. Each shard has its own lock and
+// applies LRU replacement independently, so the least recently used data is
+// chosen per shard rather than globally.
+type TypedShardedCache[K comparable, V any] struct {
+	// shards are the independent caches that hold the data.
+	shards []*TypedCache[K, V]
+
+	// seed is the seed of the key hash. It is random unless the cache has a
+	// write-ahead log.
+	seed uint64
+
+	// config is the configuration that the cache is created with.
+	config Config
+}
+
+// NewSharded creates a new sharded cache with the given number of shards and
+// total capacity. The capacity and the maximum cost are split across the
+// shards, so the capacity cannot be less than the shard count. Other options
+// are applied to every shard.
+func NewSharded(shards, cap int, opts ...Option) (*ShardedCache, error) {
+	return NewTypedSharded[any, any](shards, cap, opts...)
+}
+
+// NewTypedSharded creates a new sharded cache that stores values of type V
+// with keys of type K. The capacity and the maximum cost are split across the
+// shards, so the capacity cannot be less than the shard count. Other options
+// are applied to every shard. If a write-ahead log path is given, every shard
+// has its own log with the shard index appended to the path, and the keys are
+// assigned to the shards with a fixed hash seed, so every key is restored in
+// its own shard. The shard count should not change between restarts; if it
+// does, the restored data is moved to the shards of the keys, and the shards
+// that overflow evict their victims.
+func NewTypedSharded[K comparable, V any](shards, cap int, opts ...Option) (*TypedShardedCache[K, V], error) {
+	if shards <= 0 {
+		return nil, ErrShardCount
+	}
+	opts = append(opts[:len(opts):len(opts)], WithCapacity(cap))
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cap < shards {
+		return nil, ErrShardCapacity
+	}
+
+	s := &TypedShardedCache[K, V]{
+		shards: make([]*TypedCache[K, V], shards),
+		seed:   newSeed(),
+		config: cfg,
+	}
+	if cfg.WALPath != "" {
+		s.seed = walShardSeed
+	}
+	for i := range s.shards {
+		shardOpts := append(opts[:len(opts):len(opts)], WithMaxCost(shardCost(cfg.MaxCost, shards, i)))
+		if cfg.WALPath != "" {
+			shardOpts = append(shardOpts, WithWAL(fmt.Sprintf("%s.%d", cfg.WALPath, i)))
+		}
+		c, err := NewTyped[K, V](shardCap(cap, shards, i), shardOpts...)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.shards[i] = c
+	}
+	if cfg.WALPath != "" {
+		s.rebalance()
+	}
+	return s, nil
+}
+
+// Config returns the effective configuration of the sharded cache. The
+// capacity and the maximum cost are the totals of the shards, the callbacks
+// are the ones of the shards, and the log path is the one that the shard
+// indexes are appended to.
+func (s *TypedShardedCache[K, V]) Config() Config {
+	cfg := s.config
+	cfg.Capacity = s.Cap()
+	cfg.MaxCost = s.MaxCost()
+	shard := s.shards[0].Config()
+	cfg.OnEvict, cfg.Weigher, cfg.Loader = shard.OnEvict, shard.Weigher, shard.Loader
+	return cfg
+}
+
+// Add saves data to the shard of the key. If the shard is full, its
+// least-recently used data is removed. If you do not want to add an expired
+// time for data, you need to pass 0.
+func (s *TypedShardedCache[K, V]) Add(key K, val V, exp time.Duration) error {
+	return s.shard(key).Add(key, val, exp)
+}
+
+// Get retrieves the data of the given key and moves it to the front of its
+// shard. If there is no such data or the data is expired, it returns the zero
+// value of V and false.
+func (s *TypedShardedCache[K, V]) Get(key K) (V, bool) {
+	return s.shard(key).Get(key)
+}
+
+// GetOrLoad returns the value of the given key, or loads and adds it if the
+// key does not exist. Concurrent loads of the same key are deduplicated. See
+// TypedCache.GetOrLoad.
+func (s *TypedShardedCache[K, V]) GetOrLoad(key K, loader func() (V, time.Duration, error)) (V, error) {
+	return s.shard(key).GetOrLoad(key, loader)
+}
+
+// GetOrAdd returns the value of the given key if it exists, or adds the given
+// value atomically. See TypedCache.GetOrAdd.
+func (s *TypedShardedCache[K, V]) GetOrAdd(key K, val V, exp time.Duration) (actual V, loaded bool, err error) {
+	return s.shard(key).GetOrAdd(key, val, exp)
+}
+
+// AddOrReplace adds the data, or replaces the value and the expiration of the
+// existing data.
+func (s *TypedShardedCache[K, V]) AddOrReplace(key K, val V, exp time.Duration) error {
+	return s.shard(key).AddOrReplace(key, val, exp)
+}
+
+// Compute atomically changes the value of the given key. See
+// TypedCache.Compute.
+func (s *TypedShardedCache[K, V]) Compute(key K, fn func(old V, exists bool) (val V, keep bool)) (V, error) {
+	return s.shard(key).Compute(key, fn)
+}
+
+// CompareAndSwap replaces the value of the given key with new if its current
+// value is equal to old. See TypedCache.CompareAndSwap.
+func (s *TypedShardedCache[K, V]) CompareAndSwap(key K, old, new V) (bool, error) {
+	return s.shard(key).CompareAndSwap(key, old, new)
+}
+
+// CompareAndDelete removes the data of the given key if its current value is
+// equal to old. See TypedCache.CompareAndDelete.
+func (s *TypedShardedCache[K, V]) CompareAndDelete(key K, old V) bool {
+	return s.shard(key).CompareAndDelete(key, old)
+}
+
+// Remove deletes the data of the given key. It returns error only if the
+// whole cache is empty.
+func (s *TypedShardedCache[K, V]) Remove(key K) error {
+	if s.Len() == 0 {
+		return ErrEmptyCache
+	}
+	err := s.shard(key).Remove(key)
+	if errors.Is(err, ErrEmptyCache) {
+		return nil
+	}
+	return err
+}
+
+// Contains checks whether the given key exists without changing the access
+// order.
+func (s *TypedShardedCache[K, V]) Contains(key K) bool {
+	return s.shard(key).Contains(key)
+}
+
+// Clear deletes all data from all shards.
+func (s *TypedShardedCache[K, V]) Clear() {
+	for _, c := range s.shards {
+		c.Clear()
+	}
+}
+
+// Keys returns the unexpired keys of all shards. The keys are ordered by
+// recency within each shard, but there is no order across the shards.
+func (s *TypedShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, c := range s.shards {
+		keys = append(keys, c.Keys()...)
+	}
+	return keys
+}
+
+// Peek returns the data of the given key without changing the access order.
+func (s *TypedShardedCache[K, V]) Peek(key K) (V, bool) {
+	return s.shard(key).Peek(key)
+}
+
+// RemoveOldest removes the least recently used data of the longest shard,
+// which approximates the least recently used data of the whole cache. It
+// returns the removed key, value and whether any data is removed.
+func (s *TypedShardedCache[K, V]) RemoveOldest() (k K, v V, ok bool) {
+	var longest *TypedCache[K, V]
+	for _, c := range s.shards {
+		if longest == nil || c.Len() > longest.Len() {
+			longest = c
+		}
+	}
+	return longest.RemoveOldest()
+}
+
+// Resize changes the total capacity and splits it across the shards. It
+// returns the number of removed data. The size cannot be less than the shard
+// count; such sizes are raised to the shard count.
+func (s *TypedShardedCache[K, V]) Resize(size int) int {
+	if size < len(s.shards) {
+		size = len(s.shards)
+	}
+	var diff int
+	for i, c := range s.shards {
+		diff += c.Resize(shardCap(size, len(s.shards), i))
+	}
+	return diff
+}
+
+// Len returns the total length of the shards.
+func (s *TypedShardedCache[K, V]) Len() int {
+	var n int
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// ActiveLen returns the total number of unexpired data in the shards.
+func (s *TypedShardedCache[K, V]) ActiveLen() int {
+	var n int
+	for _, c := range s.shards {
+		n += c.ActiveLen()
+	}
+	return n
+}
+
+// Cap returns the total capacity of the shards.
+func (s *TypedShardedCache[K, V]) Cap() int {
+	var n int
+	for _, c := range s.shards {
+		n += c.Cap()
+	}
+	return n
+}
+
+// Replace changes the value of the given key without changing the access
+// order. If the key does not exist, it returns error.
+func (s *TypedShardedCache[K, V]) Replace(key K, val V) error {
+	return s.shard(key).Replace(key, val)
+}
+
+// ClearExpiredData deletes the expired data in all shards.
+func (s *TypedShardedCache[K, V]) ClearExpiredData() {
+	for _, c := range s.shards {
+		c.ClearExpiredData()
+	}
+}
+
+// UpdateVal updates the value of the given key and moves it to the front of
+// its shard. It returns the updated item.
+func (s *TypedShardedCache[K, V]) UpdateVal(key K, val V) (TypedItem[K, V], error) {
+	return s.shard(key).UpdateVal(key, val)
+}
+
+// UpdateExpirationDate updates the expiration date of the given key and moves
+// it to the front of its shard. It returns the updated item.
+func (s *TypedShardedCache[K, V]) UpdateExpirationDate(key K, exp time.Duration) (TypedItem[K, V], error) {
+	return s.shard(key).UpdateExpirationDate(key, exp)
+}
+
+// TTL returns the remaining lifetime of the given key. It returns NoExpiration
+// if the data never expires.
+func (s *TypedShardedCache[K, V]) TTL(key K) (time.Duration, bool) {
+	return s.shard(key).TTL(key)
+}
+
+// SetWeigher registers fn as the weigher of all shards.
+func (s *TypedShardedCache[K, V]) SetWeigher(fn func(key K, val V) int64) {
+	for _, c := range s.shards {
+		c.SetWeigher(fn)
+	}
+}
+
+// SetLoader registers loader as the background refresh loader of all shards.
+func (s *TypedShardedCache[K, V]) SetLoader(loader func(key K) (V, time.Duration, error)) {
+	for _, c := range s.shards {
+		c.SetLoader(loader)
+	}
+}
+
+// Cost returns the total cost of the data in all shards.
+func (s *TypedShardedCache[K, V]) Cost() int64 {
+	var n int64
+	for _, c := range s.shards {
+		n += c.Cost()
+	}
+	return n
+}
+
+// MaxCost returns the total maximum cost of the shards. Zero means that there
+// is no cost limit.
+func (s *TypedShardedCache[K, V]) MaxCost() int64 {
+	var n int64
+	for _, c := range s.shards {
+		n += c.MaxCost()
+	}
+	return n
+}
+
+// ResizeCost changes the total maximum cost and splits it across the shards.
+// It returns the number of removed data. A negative maximum cost returns
+// ErrInvalidConfig.
+func (s *TypedShardedCache[K, V]) ResizeCost(maxCost int64) (int, error) {
+	if maxCost < 0 {
+		return 0, fmt.Errorf("%w: maximum cost %d is negative", ErrInvalidConfig, maxCost)
+	}
+	var diff int
+	for i, c := range s.shards {
+		n, err := c.ResizeCost(shardCost(maxCost, len(s.shards), i))
+		if err != nil {
+			return diff, err
+		}
+		diff += n
+	}
+	return diff, nil
+}
+
+// OnEvict registers fn as the eviction callback of all shards.
+func (s *TypedShardedCache[K, V]) OnEvict(fn func(key K, val V, reason EvictReason)) {
+	for _, c := range s.shards {
+		c.OnEvict(fn)
+	}
+}
+
+// Stats returns the sum of the statistics of all shards.
+func (s *TypedShardedCache[K, V]) Stats() Stats {
+	total := Stats{Evictions: make(map[EvictReason]uint64)}
+	for _, c := range s.shards {
+		st := c.Stats()
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Adds += st.Adds
+		total.Updates += st.Updates
+		total.StaleHits += st.StaleHits
+		total.Refreshes += st.Refreshes
+		total.Expirations += st.Expirations
+		for r, n := range st.Evictions {
+			total.Evictions[r] += n
+		}
+		total.Len += st.Len
+		total.Cap += st.Cap
+		total.Cost += st.Cost
+		total.MaxCost += st.MaxCost
+	}
+	return total
+}
+
+// ResetStats sets the statistics counters of all shards to zero.
+func (s *TypedShardedCache[K, V]) ResetStats() {
+	for _, c := range s.shards {
+		c.ResetStats()
+	}
+}
+
+// Sync flushes the write-ahead logs of all shards to the disk. It returns the
+// errors that the logs encountered.
+func (s *TypedShardedCache[K, V]) Sync() error {
+	var errs []error
+	for _, c := range s.shards {
+		errs = append(errs, c.Sync())
+	}
+	return errors.Join(errs...)
+}
+
+// Compact rewrites the write-ahead logs of all shards with their current
+// data.
+func (s *TypedShardedCache[K, V]) Compact() error {
+	var errs []error
+	for _, c := range s.shards {
+		errs = append(errs, c.Compact())
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the background cleanup goroutines of all shards and closes
+// their write-ahead logs. It is safe to call Close multiple times.
+func (s *TypedShardedCache[K, V]) Close() {
+	for _, c := range s.shards {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
+// shard returns the shard that is responsible for the given key.
+func (s *TypedShardedCache[K, V]) shard(key K) *TypedCache[K, V] {
+	return s.shards[hashKey(s.seed, key)%uint64(len(s.shards))]
+}
+
+// rebalance moves the data replayed from the write-ahead logs to the shards
+// of their keys. The shard of a key does not change between restarts unless
+// the shard count changes, so there is usually nothing to move. The moves are
+// counted in the statistics, and if a shard overflows, its victims are
+// evicted like in Add.
+func (s *TypedShardedCache[K, V]) rebalance() {
+	for i, c := range s.shards {
+		for _, item := range c.snapshot() {
+			dst := s.shard(item.Key)
+			if dst == s.shards[i] {
+				continue
+			}
+			c.mu.Lock()
+			c.delete(item.Key, ReasonRemoved)
+			c.unlock()
+			dst.mu.Lock()
+			_ = dst.set(item)
+			dst.unlock()
+		}
+	}
+}
+
+// shardCap returns the capacity of the i-th shard when cap is split across n
+// shards. The remainder is given to the first shards.
+func shardCap(cap, n, i int) int {
+	c := cap / n
+	if i < cap%n {
+		c++
+	}
+	return c
+}
+
+// shardCost returns the maximum cost of the i-th shard when maxCost is split
+// across n shards. Every shard gets at least one, so that a positive maximum
+// cost never turns into no limit.
+func shardCost(maxCost int64, n, i int) int64 {
+	if maxCost <= 0 {
+		return 0
+	}
+	c := maxCost / int64(n)
+	if int64(i) < maxCost%int64(n) {
+		c++
+	}
+	if c == 0 {
+		c = 1
+	}
+	return c
+}
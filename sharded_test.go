@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// createShardedCache is a helper function to create sharded cache for test
+// functions.
+func createShardedCache(t *testing.T, shards, cap int) *ShardedCache {
+	t.Helper()
+	s, err := NewSharded(shards, cap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSharded(t *testing.T) {
+	tests := []struct {
+		name     string
+		shards   int
+		capacity int
+		wantErr  error
+		wantCaps []int
+	}{
+		{name: "returns error when shard count is zero", shards: 0, capacity: 10, wantErr: ErrShardCount},
+		{name: "returns error when capacity is zero", shards: 2, capacity: 0, wantErr: ErrZeroCapacity},
+		{name: "returns error when capacity is negative", shards: 2, capacity: -1, wantErr: ErrNegativeCapacity},
+		{name: "returns error when capacity < shard count", shards: 4, capacity: 3, wantErr: ErrShardCapacity},
+		{name: "splits capacity evenly", shards: 2, capacity: 10, wantCaps: []int{5, 5}},
+		{name: "gives remainder to first shards", shards: 3, capacity: 11, wantCaps: []int{4, 4, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSharded(tt.shards, tt.capacity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewSharded() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for i, c := range s.shards {
+				if c.Cap() != tt.wantCaps[i] {
+					t.Errorf("unexpected capacity of shard %d, got %v, want %v", i, c.Cap(), tt.wantCaps[i])
+				}
+			}
+			if s.Cap() != tt.capacity {
+				t.Errorf("unexpected total capacity, got %v, want %v", s.Cap(), tt.capacity)
+			}
+		})
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	s := createShardedCache(t, 4, 100)
+	for i := 0; i < 50; i++ {
+		if err := s.Add(i, i*2, 0); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+	if err := s.Add(0, 0, 0); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrKeyExists)
+	}
+	if s.Len() != 50 {
+		t.Errorf("unexpected length, got %v, want %v", s.Len(), 50)
+	}
+	for i := 0; i < 50; i++ {
+		if got, found := s.Get(i); !found || got != i*2 {
+			t.Errorf("s.Get(%d) = (%v, %v), want (%v, true)", i, got, found, i*2)
+		}
+	}
+	if got, found := s.Peek(100); found || got != nil {
+		t.Errorf("s.Peek() = (%v, %v), want (nil, false)", got, found)
+	}
+	if !s.Contains(10) || s.Contains(100) {
+		t.Error("unexpected s.Contains() result")
+	}
+
+	keys := s.Keys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].(int) < keys[j].(int) })
+	for i, key := range keys {
+		if key != i {
+			t.Errorf("unexpected key at index %d, got %v", i, key)
+		}
+	}
+
+	if err := s.Replace(1, 100); err != nil {
+		t.Errorf("unexpected error, got %v", err)
+	}
+	if item, err := s.UpdateVal(2, 200); err != nil || item.Val != 200 {
+		t.Errorf("s.UpdateVal() = (%v, %v)", item, err)
+	}
+	if _, err := s.UpdateExpirationDate(3, -1*time.Hour); err != nil {
+		t.Errorf("unexpected error, got %v", err)
+	}
+	s.ClearExpiredData()
+	if s.Contains(3) {
+		t.Error("expected expired key to be cleared")
+	}
+
+	if err := s.Remove(1); err != nil {
+		t.Errorf("unexpected error, got %v", err)
+	}
+	if err := s.Remove(1000); err != nil {
+		t.Errorf("unexpected error for nonexistent key, got %v", err)
+	}
+	if _, _, ok := s.RemoveOldest(); !ok {
+		t.Error("expected RemoveOldest to remove data")
+	}
+	if s.Len() != 47 {
+		t.Errorf("unexpected length, got %v, want %v", s.Len(), 47)
+	}
+
+	st := s.Stats()
+	if st.Hits != 50 || st.Adds != 50 || st.Len != 47 || st.Cap != 100 {
+		t.Errorf("unexpected stats, got %+v", st)
+	}
+
+	s.Clear()
+	if s.Len() != 0 {
+		t.Errorf("unexpected length, got %v, want %v", s.Len(), 0)
+	}
+	if err := s.Remove(1); !errors.Is(err, ErrEmptyCache) {
+		t.Errorf("unexpected error, got %v, want %v", err, ErrEmptyCache)
+	}
+	if _, _, ok := s.RemoveOldest(); ok {
+		t.Error("expected RemoveOldest not to remove data from empty cache")
+	}
+}
+
+func TestShardedCache_Resize(t *testing.T) {
+	s := createShardedCache(t, 2, 10)
+	for i := 0; i < 10; i++ {
+		_ = s.Add(fmt.Sprint(i), i, 0)
+	}
+	before := s.Len()
+	var evicted int
+	s.OnEvict(func(key, val any, reason EvictReason) {
+		evicted++
+	})
+	diff := s.Resize(4)
+	if s.Cap() != 4 {
+		t.Errorf("unexpected capacity, got %v, want %v", s.Cap(), 4)
+	}
+	if s.Len() > 4 {
+		t.Errorf("unexpected length, got %v, want at most %v", s.Len(), 4)
+	}
+	if diff != evicted || s.Len()+diff != before {
+		t.Errorf("unexpected diff, got %v, evicted %v, length %v", diff, evicted, s.Len())
+	}
+	if s.Resize(1); s.Cap() != 2 {
+		t.Errorf("unexpected capacity, got %v, want shard count %v", s.Cap(), 2)
+	}
+}
+
+// benchmarkParallel runs a mixed Get and Add workload in parallel on a full
+// cache with n keys.
+func benchmarkParallel(b *testing.B, n int, add func(k, v int), get func(k int)) {
+	for i := 0; i < n; i++ {
+		add(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				add(i%(2*n), i)
+			} else {
+				get(i % n)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_Parallel(b *testing.B) {
+	for _, n := range benchSizes[:2] {
+		b.Run(fmt.Sprintf("size=%d", n), func(b *testing.B) {
+			c, _ := NewTyped[int, int](n)
+			benchmarkParallel(b, n, func(k, v int) { _ = c.Add(k, v, 0) }, func(k int) { c.Get(k) })
+		})
+	}
+}
+
+func BenchmarkShardedCache_Parallel(b *testing.B) {
+	for _, n := range benchSizes[:2] {
+		for _, shards := range []int{4, 16, 64} {
+			if shards > n {
+				continue
+			}
+			b.Run(fmt.Sprintf("size=%d/shards=%d", n, shards), func(b *testing.B) {
+				s, _ := NewTypedSharded[int, int](shards, n)
+				benchmarkParallel(b, n, func(k, v int) { _ = s.Add(k, v, 0) }, func(k int) { s.Get(k) })
+			})
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format. It is written to the
+// header of every snapshot. Version 2 stores the expiration as a time instead
+// of nanoseconds, and version 3 adds the TTL. Version 2 snapshots can still be
+// loaded, and their data is restored without the TTL.
+const snapshotVersion = 3
+
+// minSnapshotVersion is the oldest snapshot version that LoadFrom accepts.
+const minSnapshotVersion = 2
+
+// Codec encodes and decodes the cache snapshots. GobCodec and JSONCodec are
+// the built-in codecs.
+type Codec interface {
+	// NewEncoder returns an encoder that writes to w.
+	NewEncoder(w io.Writer) Encoder
+
+	// NewDecoder returns a decoder that reads from r.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes the encoded values to a stream. *gob.Encoder and
+// *json.Encoder implement it.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads the encoded values from a stream. *gob.Decoder and
+// *json.Decoder implement it.
+type Decoder interface {
+	Decode(v any) error
+}
+
+var (
+	// GobCodec encodes the snapshots with encoding/gob. It is the default
+	// codec. The concrete types stored in a Cache, which holds the keys and
+	// the values as interfaces, need to be registered with gob.Register,
+	// except for the basic types.
+	GobCodec Codec = gobCodec{}
+
+	// JSONCodec encodes the snapshots with encoding/json. Since JSON does not
+	// record the types, it is suitable for TypedCache with concrete types.
+	// The values of a Cache are decoded as the default JSON types, such as
+	// float64 for the numbers.
+	JSONCodec Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// snapshotHeader is written before the items of a snapshot.
+type snapshotHeader struct {
+	Version int
+	Len     int
+}
+
+// storedItem is the encoded form of an item in the snapshots and the log
+// records. The codecs skip the unexported fields of TypedItem, so the TTL,
+// which sliding expiration and refresh-ahead need, is exported here. The cost
+// is not stored, since it is computed again when the item is added.
+type storedItem[K comparable, V any] struct {
+	Key        K
+	Val        V
+	Expiration time.Time
+	TTL        time.Duration
+}
+
+// newStoredItem returns the encoded form of item.
+func newStoredItem[K comparable, V any](item TypedItem[K, V]) storedItem[K, V] {
+	return storedItem[K, V]{Key: item.Key, Val: item.Val, Expiration: item.Expiration, TTL: item.ttl}
+}
+
+// item returns the item that s is encoded from.
+func (s storedItem[K, V]) item() TypedItem[K, V] {
+	return TypedItem[K, V]{Key: s.Key, Val: s.Val, Expiration: s.Expiration, ttl: s.TTL}
+}
+
+// SaveTo writes all data in cache to w with the codec of the cache, which is
+// GobCodec unless another one is given WithCodec. The data is written from
+// the least recently used one to the most recently used one, so LoadFrom
+// restores the same order. Expired data is not written. It does not change
+// frequency of the item access.
+func (c *TypedCache[K, V]) SaveTo(w io.Writer) error {
+	items := c.snapshot()
+	enc := c.codec.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Len: len(items)}); err != nil {
+		return fmt.Errorf("encode snapshot header: %w", err)
+	}
+	for i := range items {
+		if err := enc.Encode(newStoredItem(items[i])); err != nil {
+			return fmt.Errorf("encode item: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadFrom reads the data written by SaveTo from r and adds it to the cache in
+// the saved order, so the most recently used data of the snapshot becomes the
+// most recently used data of the cache. The existing data with the same key
+// is replaced. The data that is expired by the time it is loaded is skipped.
+// If the snapshot holds more data than the capacity, the least recently used
+// data is evicted. Nothing is added if the snapshot cannot be read.
+func (c *TypedCache[K, V]) LoadFrom(r io.Reader) error {
+	dec := c.codec.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("decode snapshot header: %w", err)
+	}
+	if header.Version < minSnapshotVersion || header.Version > snapshotVersion || header.Len < 0 {
+		return fmt.Errorf("%w: version %d, length %d", ErrInvalidSnapshot, header.Version, header.Len)
+	}
+	items := make([]TypedItem[K, V], 0, minInt(header.Len, c.Cap()))
+	for i := 0; i < header.Len; i++ {
+		var item storedItem[K, V]
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("decode item %d: %w", i, err)
+		}
+		items = append(items, item.item())
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	now := c.clock.Now()
+	for _, item := range items {
+		if item.expired(now) {
+			continue
+		}
+		if err := c.set(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveFile writes the snapshot of the cache to the file at path. The file is
+// replaced atomically, so the previous snapshot stays intact if saving fails.
+func (c *TypedCache[K, V]) SaveFile(path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err = c.SaveTo(f); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile reads the snapshot at path written by SaveFile. See LoadFrom.
+func (c *TypedCache[K, V]) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.LoadFrom(f)
+}
+
+// snapshot returns the unexpired items from the least recently used one to
+// the most recently used one.
+func (c *TypedCache[K, V]) snapshot() []TypedItem[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	items := make([]TypedItem[K, V], 0, c.Len())
+	for e := c.lst.back(); e != nil; e = c.lst.prev(e) {
+		if item := e.item; !item.expired(now) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
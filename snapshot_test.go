@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+func TestCache_SaveToLoadFrom(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    Codec
+		capacity int
+		addPairs [][]any
+		access   []any
+		wantKeys []any
+	}{
+		{
+			name:     "restores recency order with gob",
+			codec:    GobCodec,
+			capacity: 5,
+			addPairs: [][]any{{"a", "1", 0 * time.Hour}, {"b", "2", time.Hour}, {"c", "3", 0 * time.Hour}},
+			access:   []any{"a"},
+			wantKeys: []any{"a", "c", "b"},
+		},
+		{
+			name:     "restores recency order with JSON",
+			codec:    JSONCodec,
+			capacity: 5,
+			addPairs: [][]any{{"a", "1", 0 * time.Hour}, {"b", "2", time.Hour}, {"c", "3", 0 * time.Hour}},
+			access:   []any{"b"},
+			wantKeys: []any{"b", "c", "a"},
+		},
+		{
+			name:     "skips expired data",
+			codec:    GobCodec,
+			capacity: 5,
+			addPairs: [][]any{{"a", "1", -1 * time.Hour}, {"b", "2", time.Hour}},
+			wantKeys: []any{"b"},
+		},
+		{
+			name:     "saves empty cache",
+			codec:    GobCodec,
+			capacity: 5,
+			wantKeys: []any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := New(tt.capacity, WithCodec(tt.codec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			addItemsWithExp(t, src, tt.addPairs)
+			for _, key := range tt.access {
+				src.Get(key)
+			}
+
+			var buf bytes.Buffer
+			if err := src.SaveTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			dst, err := New(tt.capacity, WithCodec(tt.codec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dst.LoadFrom(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			cmpCacheListOrder(t, dst, tt.wantKeys)
+			for _, key := range tt.wantKeys {
+				want, _ := findItem(t, src, key)
+				got, _ := findItem(t, dst, key)
+				if got.Val != want.Val || !got.Expiration.Equal(want.Expiration) {
+					t.Errorf("item %v = %+v, want %+v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCache_LoadFromTyped(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	for _, codec := range []Codec{GobCodec, JSONCodec} {
+		src, err := NewTyped[int, user](5, WithCodec(codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = src.Add(1, user{"foo", 20}, 0)
+		_ = src.Add(2, user{"bar", 30}, time.Hour)
+
+		var buf bytes.Buffer
+		if err := src.SaveTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		dst, err := NewTyped[int, user](5, WithCodec(codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dst.LoadFrom(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := dst.Keys(), []int{2, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("keys = %v, want %v", got, want)
+		}
+		if got, _ := dst.Peek(1); got != (user{"foo", 20}) {
+			t.Errorf("val = %v, want %v", got, user{"foo", 20})
+		}
+	}
+}
+
+func TestCache_LoadFromExpiredAfterSave(t *testing.T) {
+	clock := cachetest.NewClock(time.Now())
+	src, err := New(5, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addItemsWithExp(t, src, [][]any{{k, v, 20 * time.Millisecond}, {k + k, v + v, 0 * time.Hour}})
+	var buf bytes.Buffer
+	if err := src.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Millisecond)
+
+	dst, err := New(5, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.LoadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cmpCacheListOrder(t, dst, []any{k + k})
+}
+
+func TestCache_LoadFromOverCapacity(t *testing.T) {
+	src := createCache(t, 5)
+	addItems(t, src, [][]any{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}})
+	var buf bytes.Buffer
+	if err := src.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := createCache(t, 3)
+	addItems(t, dst, [][]any{{"c", 30}, {"x", 0}})
+	if err := dst.LoadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cmpCacheListOrder(t, dst, []any{"d", "c", "b"})
+	if val, _ := dst.Peek("c"); val != 3 {
+		t.Errorf("val = %v, want 3", val)
+	}
+}
+
+func TestCache_LoadFromInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    func(t *testing.T) []byte
+		wantErr error
+	}{
+		{
+			name: "unknown version",
+			data: func(t *testing.T) []byte {
+				var buf bytes.Buffer
+				_ = GobCodec.NewEncoder(&buf).Encode(snapshotHeader{Version: snapshotVersion + 1})
+				return buf.Bytes()
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "truncated items",
+			data: func(t *testing.T) []byte {
+				c := createCache(t, 5)
+				addItems(t, c, [][]any{{k, v}, {k + k, v + v}})
+				var buf bytes.Buffer
+				if err := c.SaveTo(&buf); err != nil {
+					t.Fatal(err)
+				}
+				return buf.Bytes()[:buf.Len()-3]
+			},
+		},
+		{
+			name: "empty input",
+			data: func(t *testing.T) []byte {
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createCache(t, 5)
+			err := c.LoadFrom(bytes.NewReader(tt.data(t)))
+			if err == nil {
+				t.Fatal("invalid snapshot is loaded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if c.Len() != 0 {
+				t.Errorf("Len = %d, want 0", c.Len())
+			}
+		})
+	}
+}
+
+func TestCache_LoadFromKeepsTTL(t *testing.T) {
+	for name, codec := range map[string]Codec{"gob": GobCodec, "json": JSONCodec} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			clock := cachetest.NewClock(start)
+			src, err := NewTyped[string, string](5, WithCodec(codec), WithClock(clock))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = src.Add(k, v, time.Minute)
+			var buf bytes.Buffer
+			if err := src.SaveTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			sliding, err := NewTyped[string, string](5, WithCodec(codec), WithClock(clock), WithSlidingExpiration())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sliding.LoadFrom(bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				clock.Advance(40 * time.Second)
+				if _, found := sliding.Get(k); !found {
+					t.Fatalf("loaded data expired after %d reads", i)
+				}
+			}
+
+			// The refresh-ahead needs the TTL to find when half of it passes.
+			clock.Set(start.Add(40 * time.Second))
+			var loads atomic.Int32
+			refreshing := createRefreshCache(t, &loads, clock, WithCodec(codec), WithRefreshAhead(0.5))
+			if err := refreshing.LoadFrom(bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			if _, found := refreshing.Get(k); !found {
+				t.Fatal("loaded data is not found")
+			}
+			refreshing.refresher.wg.Wait()
+			if got := loads.Load(); got != 1 {
+				t.Errorf("loads = %d, want a refresh of the loaded data", got)
+			}
+		})
+	}
+}
+
+func TestCache_LoadFromVersion2(t *testing.T) {
+	// Version 2 snapshots store the items without the TTL.
+	type item struct {
+		Key        string
+		Val        string
+		Expiration time.Time
+	}
+	var buf bytes.Buffer
+	enc := GobCodec.NewEncoder(&buf)
+	_ = enc.Encode(snapshotHeader{Version: 2, Len: 1})
+	_ = enc.Encode(item{Key: k, Val: v, Expiration: time.Now().Add(time.Hour)})
+
+	c, err := NewTyped[string, string](5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.peek(k); got.Val != v || got.ttl != 0 {
+		t.Errorf("item = %+v, want the value without the TTL", got)
+	}
+}
+
+func TestCache_SaveFileLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	src := createCache(t, 5)
+	addItems(t, src, [][]any{{k, v}, {k + k, v + v}})
+	if err := src.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	_ = src.Add(k+k+k, v+v+v, 0)
+	if err := src.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := createCache(t, 5)
+	if err := dst.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	cmpCacheListOrder(t, dst, []any{k + k + k, k + k, k})
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files, want only the snapshot", len(entries))
+	}
+	if err := dst.LoadFile(path + ".missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want %v", err, os.ErrNotExist)
+	}
+}
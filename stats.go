@@ -0,0 +1,130 @@
+package cache
+
+import "sync/atomic"
+
+// Stats is a snapshot of the cache statistics. The counters are collected
+// since the cache is created or the last call of ResetStats.
+type Stats struct {
+	// Hits is the number of Get and Peek calls which found the data.
+	Hits uint64
+
+	// Misses is the number of Get and Peek calls which did not find the data.
+	Misses uint64
+
+	// Adds is the number of data added to the cache.
+	Adds uint64
+
+	// Updates is the number of data changed by Replace, UpdateVal and
+	// UpdateExpirationDate.
+	Updates uint64
+
+	// StaleHits is the number of Get calls which returned expired data during
+	// the stale-while-revalidate window. They are also counted in Hits.
+	StaleHits uint64
+
+	// Refreshes is the number of background refreshes started by Get.
+	Refreshes uint64
+
+	// Expirations is the number of expired data removed from the cache. It
+	// is equal to Evictions[ReasonExpired].
+	Expirations uint64
+
+	// Evictions is the number of removed data by the reason of removal. The
+	// reasons are the same as the ones reported to the OnEvict callback.
+	Evictions map[EvictReason]uint64
+
+	// Len is the current length of the cache.
+	Len int
+
+	// Cap is the current capacity of the cache.
+	Cap int
+
+	// Cost is the current total cost of the cached data.
+	Cost int64
+
+	// MaxCost is the current maximum total cost. Zero means that there is no
+	// cost limit.
+	MaxCost int64
+}
+
+// HitRatio returns the ratio of hits to all lookups. It returns zero if there
+// is no lookup yet.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// stats holds the counters of the cache. The counters are updated atomically,
+// so they can be read without holding the cache lock.
+type stats struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	adds      atomic.Uint64
+	updates   atomic.Uint64
+	staleHits atomic.Uint64
+	refreshes atomic.Uint64
+	evictions [ReasonReplaced + 1]atomic.Uint64
+}
+
+// hit increments the hit or miss counter depending on found.
+func (s *stats) hit(found bool) {
+	if found {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+// reset sets all counters to zero.
+func (s *stats) reset() {
+	s.hits.Store(0)
+	s.misses.Store(0)
+	s.adds.Store(0)
+	s.updates.Store(0)
+	s.staleHits.Store(0)
+	s.refreshes.Store(0)
+	for i := range s.evictions {
+		s.evictions[i].Store(0)
+	}
+}
+
+// snapshot returns the counters as Stats. The length, the capacity and the
+// costs are filled by the caller.
+func (s *stats) snapshot() Stats {
+	st := Stats{
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Adds:      s.adds.Load(),
+		Updates:   s.updates.Load(),
+		StaleHits: s.staleHits.Load(),
+		Refreshes: s.refreshes.Load(),
+		Evictions: make(map[EvictReason]uint64),
+	}
+	for r := ReasonCapacity; r <= ReasonReplaced; r++ {
+		if n := s.evictions[r].Load(); n > 0 {
+			st.Evictions[r] = n
+		}
+	}
+	st.Expirations = st.Evictions[ReasonExpired]
+	return st
+}
+
+// Stats returns the current statistics of the cache.
+func (c *TypedCache[K, V]) Stats() Stats {
+	c.mu.RLock()
+	l, cp, cost, maxCost := c.Len(), c.Cap(), c.Cost(), c.MaxCost()
+	c.mu.RUnlock()
+
+	s := c.stats.snapshot()
+	s.Len, s.Cap, s.Cost, s.MaxCost = l, cp, cost, maxCost
+	return s
+}
+
+// ResetStats sets all counters of the statistics to zero. Len, Cap, Cost and
+// MaxCost are not affected.
+func (c *TypedCache[K, V]) ResetStats() {
+	c.stats.reset()
+}
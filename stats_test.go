@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCache_Stats(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		addPairs [][]any
+		action   func(c *Cache)
+		want     Stats
+	}{
+		{
+			name:     "counts adds and capacity evictions",
+			capacity: 2,
+			addPairs: [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, 0 * time.Hour}, {k + k + k, v + v + v, 0 * time.Hour}},
+			action:   func(c *Cache) {},
+			want: Stats{
+				Adds:      3,
+				Evictions: map[EvictReason]uint64{ReasonCapacity: 1},
+				Len:       2,
+				Cap:       2,
+				Cost:      2,
+			},
+		},
+		{
+			name:     "counts hits and misses of Get and Peek",
+			capacity: 2,
+			addPairs: [][]any{{k, v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				c.Get(k)
+				c.Peek(k)
+				c.Get("nonexistent")
+			},
+			want: Stats{
+				Hits:      2,
+				Misses:    1,
+				Adds:      1,
+				Evictions: map[EvictReason]uint64{},
+				Len:       1,
+				Cap:       2,
+				Cost:      1,
+			},
+		},
+		{
+			name:     "counts expired data as misses and expirations",
+			capacity: 3,
+			addPairs: [][]any{{k, v, -1 * time.Hour}, {k + k, v + v, -1 * time.Hour}, {k + k + k, v + v + v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				c.Get(k)
+				c.ClearExpiredData()
+			},
+			want: Stats{
+				Misses:      1,
+				Adds:        3,
+				Expirations: 2,
+				Evictions:   map[EvictReason]uint64{ReasonExpired: 2},
+				Len:         1,
+				Cap:         3,
+				Cost:        1,
+			},
+		},
+		{
+			name:     "counts updates and removals",
+			capacity: 3,
+			addPairs: [][]any{{k, v, 0 * time.Hour}, {k + k, v + v, 0 * time.Hour}, {k + k + k, v + v + v, 0 * time.Hour}},
+			action: func(c *Cache) {
+				_ = c.Replace(k, v+v)
+				_, _ = c.UpdateVal(k, v)
+				_, _ = c.UpdateExpirationDate(k, time.Hour)
+				_ = c.Remove(k + k)
+				c.RemoveOldest()
+				c.Resize(1)
+				c.Clear()
+			},
+			want: Stats{
+				Adds:    3,
+				Updates: 3,
+				Evictions: map[EvictReason]uint64{
+					ReasonReplaced: 2,
+					ReasonRemoved:  2,
+					ReasonCleared:  1,
+				},
+				Len:  0,
+				Cap:  1,
+				Cost: 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		c := createCache(t, tt.capacity)
+		addItemsWithExp(t, c, tt.addPairs)
+		t.Run(tt.name, func(t *testing.T) {
+			tt.action(c)
+			if got := c.Stats(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cache.Stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCache_ResetStats(t *testing.T) {
+	c := createCache(t, 1)
+	addItems(t, c, [][]any{{k, v}, {k + k, v + v}})
+	c.Get(k)
+	c.Get(k + k)
+
+	c.ResetStats()
+	want := Stats{Evictions: map[EvictReason]uint64{}, Len: 1, Cap: 1, Cost: 1}
+	if got := c.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("cache.Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStats_HitRatio(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats Stats
+		want  float64
+	}{
+		{name: "returns zero when there is no lookup", stats: Stats{}, want: 0},
+		{name: "returns ratio of hits", stats: Stats{Hits: 3, Misses: 1}, want: 0.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.HitRatio(); got != tt.want {
+				t.Errorf("Stats.HitRatio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
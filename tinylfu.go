@@ -0,0 +1,243 @@
+package cache
+
+const (
+	// tinyLFUWindowRatio is the share of the capacity for the admission
+	// window.
+	tinyLFUWindowRatio = 0.01
+
+	// tinyLFUProtectedRatio is the share of the main space for the protected
+	// segment.
+	tinyLFUProtectedRatio = 0.8
+)
+
+// tinyLFU is the W-TinyLFU policy. New keys enter a small LRU window. Keys
+// leaving the window enter the probation segment of the main segmented LRU,
+// where they compete with the probation victim: the one with the lower
+// estimated frequency is evicted. Keys accessed in probation are promoted to
+// the protected segment.
+type tinyLFU[K comparable] struct {
+	windowCap    int
+	protectedCap int
+	window       *keyList[K]
+	probation    *keyList[K]
+	protected    *keyList[K]
+	sketch       *sketch
+	seed         uint64
+	pool         keyPool[K]
+}
+
+// newTinyLFU creates an empty tinyLFU policy for the given capacity.
+func newTinyLFU[K comparable](cap int) *tinyLFU[K] {
+	t := &tinyLFU[K]{seed: newSeed(), sketch: newSketch(cap)}
+	t.window = newKeyList(&t.pool)
+	t.probation = newKeyList(&t.pool)
+	t.protected = newKeyList(&t.pool)
+	t.resize(cap)
+	return t
+}
+
+// add puts the key into the window and moves the keys overflowing the window
+// into probation.
+func (t *tinyLFU[K]) add(key K) {
+	t.sketch.increment(hashKey(t.seed, key))
+	t.window.pushFront(key)
+	for t.window.len > t.windowCap {
+		t.probation.pushFront(t.window.popBack())
+	}
+}
+
+// access records the frequency of the key and promotes it if it is in
+// probation.
+func (t *tinyLFU[K]) access(key K) {
+	t.sketch.increment(hashKey(t.seed, key))
+	switch {
+	case t.window.contains(key):
+		t.window.moveToFront(key)
+	case t.probation.remove(key):
+		t.protected.pushFront(key)
+		for t.protected.len > t.protectedCap {
+			t.probation.pushFront(t.protected.popBack())
+		}
+	case t.protected.contains(key):
+		t.protected.moveToFront(key)
+	}
+}
+
+// remove forgets the key. Its frequency is kept in the sketch.
+func (t *tinyLFU[K]) remove(key K, reason EvictReason) {
+	if !t.window.remove(key) && !t.probation.remove(key) {
+		t.protected.remove(key)
+	}
+}
+
+// victim lets the most recent key of probation, which has just left the
+// window, compete with the least recent one. The key with the lower estimated
+// frequency is the victim.
+func (t *tinyLFU[K]) victim() K {
+	if t.probation.len == 0 {
+		if t.protected.len > 0 {
+			return t.protected.back()
+		}
+		return t.window.back()
+	}
+
+	candidate := t.probation.front()
+	var victim K
+	switch {
+	case t.probation.len > 1:
+		victim = t.probation.back()
+	case t.protected.len > 0:
+		victim = t.protected.back()
+	default:
+		return candidate
+	}
+	if t.sketch.estimate(hashKey(t.seed, candidate)) > t.sketch.estimate(hashKey(t.seed, victim)) {
+		return victim
+	}
+	return candidate
+}
+
+// resize changes the segment sizes and the sketch width for the given
+// capacity. The frequencies in the sketch are kept.
+func (t *tinyLFU[K]) resize(cap int) {
+	t.windowCap = maxInt(int(float64(cap)*tinyLFUWindowRatio), 1)
+	t.protectedCap = int(float64(cap-t.windowCap) * tinyLFUProtectedRatio)
+	t.sketch.resize(cap)
+	for t.protected.len > t.protectedCap {
+		t.probation.pushFront(t.protected.popBack())
+	}
+}
+
+// clear forgets all keys and frequencies.
+func (t *tinyLFU[K]) clear() {
+	t.window.clear()
+	t.probation.clear()
+	t.protected.clear()
+	t.sketch.clear()
+}
+
+const (
+	// sketchDepth is the number of rows of the sketch.
+	sketchDepth = 4
+
+	// sketchMaxCount is the maximum value of a counter.
+	sketchMaxCount = 15
+
+	// sketchResetFactor is the number of increments per counter width after
+	// which all counters are halved.
+	sketchResetFactor = 10
+)
+
+// sketchSeeds are the seeds that derive the row hashes from the key hash.
+var sketchSeeds = [sketchDepth]uint64{
+	0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325,
+}
+
+// sketch is a count-min sketch estimating the frequencies of the keys. The
+// counters saturate at sketchMaxCount and they are halved periodically, so
+// the old frequencies fade out.
+type sketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// newSketch creates a sketch for the given capacity.
+func newSketch(cap int) *sketch {
+	width := sketchWidth(cap)
+	s := &sketch{mask: uint64(width - 1), resetAt: width * sketchResetFactor}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// sketchWidth returns the number of counters per row for the given capacity.
+// It is a power of two, so the index is masked from the hash.
+func sketchWidth(cap int) int {
+	width := 16
+	for width < cap {
+		width <<= 1
+	}
+	return width
+}
+
+// resize changes the width of the sketch for the given capacity if it needs
+// another width. The index of a hash in the wider rows shares its low bits
+// with the index in the narrower rows, so the counters are kept: growing
+// copies every counter to the indexes with the same low bits, and shrinking
+// adds up the counters folded into the same index. The estimates never drop
+// below the counts of the old sketch.
+func (s *sketch) resize(cap int) {
+	width := sketchWidth(cap)
+	if uint64(width-1) == s.mask {
+		return
+	}
+	mask := uint64(width - 1)
+	for i, old := range s.rows {
+		row := make([]uint8, width)
+		if width > len(old) {
+			for j := range row {
+				row[j] = old[uint64(j)&s.mask]
+			}
+		} else {
+			for j, c := range old {
+				idx := uint64(j) & mask
+				row[idx] = uint8(minInt(int(row[idx])+int(c), sketchMaxCount))
+			}
+		}
+		s.rows[i] = row
+	}
+	s.mask = mask
+	s.resetAt = width * sketchResetFactor
+}
+
+// increment increases the counters of the hash.
+func (s *sketch) increment(h uint64) {
+	for i := range s.rows {
+		if idx := s.index(h, i); s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+// estimate returns the estimated frequency of the hash.
+func (s *sketch) estimate(h uint64) uint8 {
+	est := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < est {
+			est = c
+		}
+	}
+	return est
+}
+
+// reset halves all counters.
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// clear sets all counters to zero.
+func (s *sketch) clear() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+	s.additions = 0
+}
+
+// index returns the counter index of the hash in the i-th row.
+func (s *sketch) index(h uint64, i int) uint64 {
+	return mix(h^sketchSeeds[i]) & s.mask
+}
@@ -0,0 +1,91 @@
+package cache
+
+const (
+	// twoQueueInRatio is the share of the capacity for the a1in queue.
+	twoQueueInRatio = 0.25
+
+	// twoQueueOutRatio is the size of the a1out ghost queue relative to the
+	// capacity.
+	twoQueueOutRatio = 0.5
+)
+
+// twoQueue is the full 2Q policy. New keys enter the a1in FIFO queue. Keys
+// evicted from a1in are remembered in the a1out ghost queue, and a key added
+// again while it is in a1out enters the am LRU queue.
+type twoQueue[K comparable] struct {
+	inCap  int
+	outCap int
+	a1in   *keyList[K]
+	a1out  *keyList[K]
+	am     *keyList[K]
+	pool   keyPool[K]
+}
+
+// newTwoQueue creates an empty twoQueue policy for the given capacity.
+func newTwoQueue[K comparable](cap int) *twoQueue[K] {
+	q := &twoQueue[K]{}
+	q.a1in = newKeyList(&q.pool)
+	q.a1out = newKeyList(&q.pool)
+	q.am = newKeyList(&q.pool)
+	q.resize(cap)
+	return q
+}
+
+// add puts the key into am if it is in a1out, otherwise into a1in.
+func (q *twoQueue[K]) add(key K) {
+	if q.a1out.remove(key) {
+		q.am.pushFront(key)
+		return
+	}
+	q.a1in.pushFront(key)
+}
+
+// access moves the key to the front of am. Accesses in a1in are ignored, since
+// they are likely correlated with the addition.
+func (q *twoQueue[K]) access(key K) {
+	if q.am.contains(key) {
+		q.am.moveToFront(key)
+	}
+}
+
+// remove forgets the key. A key evicted from a1in is remembered in a1out.
+func (q *twoQueue[K]) remove(key K, reason EvictReason) {
+	if q.a1in.remove(key) {
+		if reason.evicted() {
+			q.a1out.pushFront(key)
+			q.trimOut()
+		}
+		return
+	}
+	q.am.remove(key)
+}
+
+// victim returns the back of a1in if it exceeds its share, otherwise the back
+// of am.
+func (q *twoQueue[K]) victim() K {
+	if q.a1in.len > 0 && (q.a1in.len > q.inCap || q.am.len == 0) {
+		return q.a1in.back()
+	}
+	return q.am.back()
+}
+
+// resize changes the sizes of the queues for the given capacity.
+func (q *twoQueue[K]) resize(cap int) {
+	q.inCap = maxInt(int(float64(cap)*twoQueueInRatio), 1)
+	q.outCap = maxInt(int(float64(cap)*twoQueueOutRatio), 1)
+	q.trimOut()
+}
+
+// clear forgets all keys.
+func (q *twoQueue[K]) clear() {
+	q.a1in.clear()
+	q.a1out.clear()
+	q.am.clear()
+}
+
+// trimOut keeps a1out within its size.
+func (q *twoQueue[K]) trimOut() {
+	for q.a1out.len > q.outCap {
+		q.a1out.popBack()
+	}
+}
@@ -0,0 +1,427 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when the write-ahead log is flushed to the disk with
+// fsync. The records are written to the log file before every mutating method
+// returns regardless of the policy, so they survive a process crash. The
+// policy only matters for an operating system crash or a power loss.
+type SyncPolicy int
+
+const (
+	// SyncInterval flushes the log to the disk in every sync interval, which
+	// is one second unless another one is given WithSyncInterval. It is the
+	// default policy.
+	SyncInterval SyncPolicy = iota
+
+	// SyncAlways flushes the log to the disk before every mutating method
+	// returns.
+	SyncAlways
+
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+// String returns the name of the sync policy.
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncInterval:
+		return "interval"
+	case SyncAlways:
+		return "always"
+	case SyncNever:
+		return "never"
+	default:
+		return fmt.Sprintf("SyncPolicy(%d)", int(p))
+	}
+}
+
+const (
+	// defaultSyncInterval is the sync interval of SyncInterval if no interval
+	// is given.
+	defaultSyncInterval = time.Second
+
+	// compactMinRecords is the record count that the log needs to reach
+	// before it is compacted automatically.
+	compactMinRecords = 1024
+
+	// walHeaderSize is the size of the record header, which holds the payload
+	// length and its CRC-32 checksum.
+	walHeaderSize = 8
+
+	// walMaxRecord is the maximum payload length. A longer length can only
+	// be read from a corrupted header.
+	walMaxRecord = 1 << 30
+)
+
+// walOp is the type of a log record.
+type walOp uint8
+
+const (
+	// opSet adds the item or replaces the existing one.
+	opSet walOp = iota + 1
+
+	// opRemove removes the item with the key.
+	opRemove
+
+	// opClear removes all items.
+	opClear
+
+	// opResize is the capacity record of the older logs. It is skipped on
+	// replay, since the capacity given to the constructor is kept and the
+	// data evicted by Resize is recorded by opRemove.
+	opResize
+)
+
+// walRecord is a single change of the cache. Every internal change of the
+// cache data, including the evictions, is recorded, so replaying the records
+// restores the same data. The capacity is not recorded. The records written
+// before the TTL is stored are replayed without the TTL.
+type walRecord[K comparable, V any] struct {
+	Op   walOp
+	Item storedItem[K, V]
+}
+
+// wal is the append-only write-ahead log of a cache. The records are buffered
+// while the cache lock is held and they are written to the file by commit
+// before the lock is released.
+type wal[K comparable, V any] struct {
+	// path is the path of the log file.
+	path string
+
+	// policy is the sync policy.
+	policy SyncPolicy
+
+	// codec encodes the record payloads.
+	codec Codec
+
+	// buf holds the records that are not written to the file yet.
+	buf bytes.Buffer
+
+	// payload is the scratch buffer to encode a record.
+	payload bytes.Buffer
+
+	// records is the record count in the log file and buf.
+	records int
+
+	// syncer flushes the log in every sync interval. It is nil unless the
+	// policy is SyncInterval.
+	syncer *janitor
+
+	// mu protects f and err, which are also used by the syncer.
+	mu sync.Mutex
+
+	// f is the log file. It is nil after the log is closed.
+	f *os.File
+
+	// err is the first error that the log encountered.
+	err error
+}
+
+// openWAL opens the log at path, replays it into c and truncates the torn
+// final record, if any. It starts the syncer for SyncInterval.
+func openWAL[K comparable, V any](c *TypedCache[K, V], path string, policy SyncPolicy, interval time.Duration) (*wal[K, V], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	valid, records, err := c.replay(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(valid); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w := &wal[K, V]{
+		path:    path,
+		policy:  policy,
+		codec:   c.codec,
+		records: records,
+		f:       f,
+	}
+	if policy == SyncInterval {
+		w.syncer = newJanitor(interval, func() { _ = w.sync() })
+	}
+	return w, nil
+}
+
+// replay applies the records read from r to the cache. It returns the length
+// of the valid records and their count. Reading stops at the first torn or
+// corrupted record, which is expected after a crash in the middle of a write.
+func (c *TypedCache[K, V]) replay(r io.Reader) (valid int64, records int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	br := bufio.NewReader(r)
+	header := make([]byte, walHeaderSize)
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return valid, records, nil
+		}
+		n := binary.LittleEndian.Uint32(header)
+		if n > walMaxRecord {
+			return valid, records, nil
+		}
+		if cap(payload) < int(n) {
+			payload = make([]byte, n)
+		}
+		payload = payload[:n]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return valid, records, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+			return valid, records, nil
+		}
+
+		var rec walRecord[K, V]
+		if err := c.codec.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+			return 0, 0, fmt.Errorf("decode log record %d: %w", records, err)
+		}
+		if err := c.apply(rec); err != nil {
+			return 0, 0, fmt.Errorf("apply log record %d: %w", records, err)
+		}
+		valid += walHeaderSize + int64(n)
+		records++
+	}
+}
+
+// apply applies a replayed record to the cache.
+func (c *TypedCache[K, V]) apply(rec walRecord[K, V]) error {
+	switch rec.Op {
+	case opSet:
+		item := rec.Item.item()
+		if item.expired(c.clock.Now()) {
+			c.delete(item.Key, ReasonExpired)
+			return nil
+		}
+		return c.set(item)
+	case opRemove:
+		c.delete(rec.Item.Key, ReasonRemoved)
+	case opClear:
+		c.clear()
+	case opResize:
+	default:
+		return fmt.Errorf("%w: unknown operation %d", ErrCorruptLog, rec.Op)
+	}
+	return nil
+}
+
+// append buffers the record. The cache lock must be held.
+func (w *wal[K, V]) append(rec walRecord[K, V]) {
+	w.payload.Reset()
+	if err := w.codec.NewEncoder(&w.payload).Encode(&rec); err != nil {
+		w.setErr(fmt.Errorf("encode log record: %w", err))
+		return
+	}
+	var header [walHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(w.payload.Len()))
+	binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(w.payload.Bytes()))
+	w.buf.Write(header[:])
+	w.buf.Write(w.payload.Bytes())
+	w.records++
+}
+
+// commit writes the buffered records to the file, compacts the log if it is
+// more than twice as long as the cache, and flushes it for SyncAlways. The
+// cache lock must be held.
+func (c *TypedCache[K, V]) commit() {
+	w := c.wal
+	if w == nil || w.buf.Len() == 0 {
+		return
+	}
+	w.mu.Lock()
+	f := w.f
+	w.mu.Unlock()
+	if f == nil {
+		w.setErr(os.ErrClosed)
+		w.buf.Reset()
+		return
+	}
+	_, err := f.Write(w.buf.Bytes())
+	w.buf.Reset()
+	if err != nil {
+		w.setErr(err)
+		return
+	}
+	if w.records >= compactMinRecords && w.records > 2*c.Len() {
+		if err := c.compact(); err != nil {
+			w.setErr(err)
+		}
+		return
+	}
+	if w.policy == SyncAlways {
+		if err := w.sync(); err != nil {
+			w.setErr(err)
+		}
+	}
+}
+
+// compact rewrites the log with the current data, so it holds a single record
+// for every item. The new log is written to a temporary file and renamed over
+// the old one, so a crash during the compaction leaves the old log intact. The
+// cache lock must be held.
+func (c *TypedCache[K, V]) compact() (err error) {
+	w := c.wal
+	tmp, err := os.Create(w.path + ".compact")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	w.buf.Reset()
+	w.records = 0
+	now := c.clock.Now()
+	for e := c.lst.back(); e != nil; e = c.lst.prev(e) {
+		if item := e.item; !item.expired(now) {
+			w.append(walRecord[K, V]{Op: opSet, Item: newStoredItem(item)})
+		}
+	}
+	_, err = tmp.Write(w.buf.Bytes())
+	w.buf.Reset()
+	if err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), w.path); err != nil {
+		return err
+	}
+	if err = syncDir(filepath.Dir(w.path)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	old := w.f
+	w.f = f
+	w.mu.Unlock()
+	return old.Close()
+}
+
+// sync flushes the log file to the disk.
+func (w *wal[K, V]) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return w.err
+	}
+	if err := w.f.Sync(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// close stops the syncer, flushes the log to the disk and closes the file. It
+// is safe to call multiple times.
+func (w *wal[K, V]) close() error {
+	if w.syncer != nil {
+		w.syncer.close()
+	}
+	err := w.sync()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return err
+	}
+	if cerr := w.f.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+// setErr records err if there is no previous error.
+func (w *wal[K, V]) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// syncDir flushes the directory entries, so a rename in the directory is
+// durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// logSet records that the item is added or changed.
+func (c *TypedCache[K, V]) logSet(item TypedItem[K, V]) {
+	if c.wal != nil {
+		c.wal.append(walRecord[K, V]{Op: opSet, Item: newStoredItem(item)})
+	}
+}
+
+// logRemove records that the item with the key is removed.
+func (c *TypedCache[K, V]) logRemove(key K) {
+	if c.wal != nil {
+		c.wal.append(walRecord[K, V]{Op: opRemove, Item: storedItem[K, V]{Key: key}})
+	}
+}
+
+// logClear records that all items are removed.
+func (c *TypedCache[K, V]) logClear() {
+	if c.wal != nil {
+		c.wal.append(walRecord[K, V]{Op: opClear})
+	}
+}
+
+// Sync flushes the write-ahead log to the disk. It returns the first error
+// that the log encountered, since a failed write cannot be reported by the
+// method that made the change. It returns nil if the cache has no log.
+func (c *TypedCache[K, V]) Sync() error {
+	if c.wal == nil {
+		return nil
+	}
+	return c.wal.sync()
+}
+
+// Compact rewrites the write-ahead log with the current data. The log is also
+// compacted automatically when it grows more than twice as long as the cache.
+// It does nothing if the cache has no log.
+func (c *TypedCache[K, V]) Compact() error {
+	if c.wal == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if err := c.compact(); err != nil {
+		c.wal.setErr(err)
+		return err
+	}
+	return nil
+}
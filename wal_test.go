@@ -0,0 +1,442 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gozeloglu/cache/cachetest"
+)
+
+// openWALCache creates a typed cache with a write-ahead log at path. It is a
+// helper function to prevent code duplication.
+func openWALCache(t *testing.T, cap int, path string, opts ...Option) *TypedCache[string, string] {
+	t.Helper()
+	c, err := NewTyped[string, string](cap, append([]Option{WithWAL(path)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// cmpWALCache compares the keys, the values and the expirations of the
+// caches.
+func cmpWALCache(t *testing.T, got, want *TypedCache[string, string]) {
+	t.Helper()
+	if !reflect.DeepEqual(sortedKeys(got), sortedKeys(want)) {
+		t.Errorf("keys = %v, want %v", sortedKeys(got), sortedKeys(want))
+	}
+	for _, key := range want.Keys() {
+		g, _ := got.peek(key)
+		w, _ := want.peek(key)
+		if g.Val != w.Val {
+			t.Errorf("val of %s = %s, want %s", key, g.Val, w.Val)
+		}
+		if !g.Expiration.Equal(w.Expiration) {
+			t.Errorf("expiration of %s = %v, want %v", key, g.Expiration, w.Expiration)
+		}
+		if g.ttl != w.ttl {
+			t.Errorf("TTL of %s = %v, want %v", key, g.ttl, w.ttl)
+		}
+	}
+}
+
+func TestCache_WALReplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(c *TypedCache[string, string])
+	}{
+		{
+			name: "Add and Remove",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", 0)
+				_ = c.Add("b", "2", time.Hour)
+				_ = c.Add("c", "3", 0)
+				_ = c.Remove("b")
+			},
+		},
+		{
+			name: "UpdateVal and Replace",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", 0)
+				_ = c.Add("b", "2", 0)
+				_, _ = c.UpdateVal("a", "10")
+				_ = c.Replace("b", "20")
+			},
+		},
+		{
+			name: "UpdateExpirationDate",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", 0)
+				_ = c.Add("b", "2", 0)
+				_, _ = c.UpdateExpirationDate("a", time.Hour)
+			},
+		},
+		{
+			name: "Clear",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", 0)
+				c.Clear()
+				_ = c.Add("b", "2", 0)
+			},
+		},
+		{
+			name: "Resize",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", 0)
+				_ = c.Add("b", "2", 0)
+				_ = c.Add("c", "3", 0)
+				c.Resize(2)
+			},
+		},
+		{
+			name: "capacity evictions after reads",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", 0)
+				_ = c.Add("b", "2", 0)
+				_ = c.Add("c", "3", 0)
+				c.Get("a")
+				_ = c.Add("d", "4", 0)
+				_ = c.Add("e", "5", 0)
+			},
+		},
+		{
+			name: "expired data",
+			action: func(c *TypedCache[string, string]) {
+				_ = c.Add("a", "1", -time.Hour)
+				_ = c.Add("b", "2", 0)
+			},
+		},
+		{
+			name: "atomic operations",
+			action: func(c *TypedCache[string, string]) {
+				_, _, _ = c.GetOrAdd("a", "1", 0)
+				_ = c.AddOrReplace("a", "2", 0)
+				_, _ = c.Compute("b", func(old string, exists bool) (string, bool) {
+					return "3", true
+				})
+				_, _ = c.CompareAndSwap("b", "3", "4")
+				c.CompareAndDelete("a", "2")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.wal")
+			src := openWALCache(t, 3, path, WithSyncPolicy(SyncAlways))
+			tt.action(src)
+			if err := src.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			src.Close()
+
+			dst := openWALCache(t, 3, path)
+			cmpWALCache(t, dst, src)
+		})
+	}
+}
+
+func TestCache_WALTornRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, path string)
+	}{
+		{
+			name: "truncated payload",
+			corrupt: func(t *testing.T, path string) {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Truncate(path, info.Size()-3); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "truncated header",
+			corrupt: func(t *testing.T, path string) {
+				f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.Write([]byte{1, 2, 3}); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "corrupted checksum",
+			corrupt: func(t *testing.T, path string) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data[len(data)-1] ^= 0xff
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.wal")
+			src := openWALCache(t, 5, path)
+			_ = src.Add("a", "1", 0)
+			_ = src.Add("b", "2", 0)
+			src.Close()
+			valid, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(t, path)
+
+			dst := openWALCache(t, 5, path)
+			keys := sortedKeys(dst)
+			if len(keys) == 0 || keys[0] != "a" {
+				t.Fatalf("keys = %v, want the records before the torn one", keys)
+			}
+			if info, _ := os.Stat(path); info.Size() > valid.Size() {
+				t.Errorf("log size = %d, want at most %d after truncation", info.Size(), valid.Size())
+			}
+
+			_ = dst.Add("c", "3", 0)
+			dst.Close()
+			again := openWALCache(t, 5, path)
+			if !again.Contains("c") {
+				t.Error("record written after truncation is not replayed")
+			}
+		})
+	}
+}
+
+func TestCache_WALCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	src := openWALCache(t, 10, path, WithSyncPolicy(SyncNever))
+	for i := 0; i < 3*compactMinRecords; i++ {
+		_ = src.AddOrReplace(string(rune('a'+i%5)), string(rune('0'+i%10)), 0)
+	}
+	if src.wal.records >= compactMinRecords {
+		t.Errorf("records = %d, want the log to be compacted", src.wal.records)
+	}
+
+	_ = src.Add("z", "9", 0)
+	if err := src.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if want := src.Len(); src.wal.records != want {
+		t.Errorf("records = %d, want %d", src.wal.records, want)
+	}
+	if _, err := os.Stat(path + ".compact"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary compaction file is left: %v", err)
+	}
+	_ = src.Add("y", "8", 0)
+	src.Close()
+
+	dst := openWALCache(t, 10, path)
+	cmpWALCache(t, dst, src)
+}
+
+func TestCache_WALSyncPolicies(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncInterval, SyncAlways, SyncNever} {
+		t.Run(policy.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.wal")
+			src := openWALCache(t, 5, path, WithSyncPolicy(policy), WithSyncInterval(time.Millisecond))
+			_ = src.Add("a", "1", 0)
+			time.Sleep(5 * time.Millisecond)
+			if err := src.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			src.Close()
+			dst := openWALCache(t, 5, path)
+			cmpWALCache(t, dst, src)
+		})
+	}
+}
+
+func TestCache_WALClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	c := openWALCache(t, 5, path)
+	c.Close()
+	c.Close()
+	_ = c.Add("a", "1", 0)
+	if err := c.Sync(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("err = %v, want %v", err, os.ErrClosed)
+	}
+}
+
+func TestCache_WALStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	src := openWALCache(t, 5, path)
+	_ = src.Add("a", "1", 0)
+	src.Close()
+
+	dst := openWALCache(t, 5, path)
+	if got := dst.Stats().Adds; got != 0 {
+		t.Errorf("adds = %d, want replayed records not to be counted", got)
+	}
+}
+
+func TestShardedCache_WAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	src, err := NewTypedSharded[string, string](4, 400, WithWAL(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		_ = src.Add(string(rune('a'+i)), string(rune('A'+i)), 0)
+	}
+	if err := src.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+
+	dst, err := NewTypedSharded[string, string](4, 400, WithWAL(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if dst.Len() != 20 {
+		t.Errorf("Len = %d, want 20", dst.Len())
+	}
+	for i := 0; i < 20; i++ {
+		key := string(rune('a' + i))
+		if val, found := dst.Get(key); !found || val != string(rune('A'+i)) {
+			t.Errorf("Get(%s) = (%s, %v), want (%s, true)", key, val, found, string(rune('A'+i)))
+		}
+	}
+	if err := dst.Compact(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShardedCache_WALFullRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	src, err := NewTypedSharded[string, string](4, 64, WithWAL(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		_ = src.Add(fmt.Sprint("key", i), fmt.Sprint("val", i), 0)
+	}
+	if src.Len() != 64 {
+		t.Fatalf("Len = %d, want a full cache", src.Len())
+	}
+	items := src.Items()
+	src.Close()
+
+	// Every restart must restore all data without evictions.
+	for restart := 0; restart < 3; restart++ {
+		dst, err := NewTypedSharded[string, string](4, 64, WithWAL(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dst.Len() != 64 {
+			t.Errorf("Len = %d after restart %d, want 64", dst.Len(), restart)
+		}
+		for _, item := range items {
+			if val, found := dst.Peek(item.Key); !found || val != item.Val {
+				t.Errorf("Peek(%s) = (%s, %v), want (%s, true)", item.Key, val, found, item.Val)
+			}
+		}
+		if st := dst.Stats(); len(st.Evictions) != 0 {
+			t.Errorf("unexpected evictions %v after restart %d", st.Evictions, restart)
+		}
+		dst.Close()
+	}
+}
+
+func TestCache_WALCapacity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	src := openWALCache(t, 4, path)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		_ = src.Add(key, key, 0)
+	}
+	src.Resize(0)
+	if err := src.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	_ = src.Add("e", "e", 0)
+	src.Close()
+
+	// The capacity given to the constructor is kept.
+	dst := openWALCache(t, 100, path)
+	if dst.Cap() != 100 {
+		t.Errorf("Cap = %d, want %d", dst.Cap(), 100)
+	}
+	cmpWALCache(t, dst, src)
+	dst.Close()
+
+	// The capacity records of the older logs, including the zero capacity
+	// written by Resize(0), are skipped.
+	rec := struct {
+		Op   walOp
+		Item storedItem[string, string]
+		Cap  int
+	}{Op: opResize}
+	var payload bytes.Buffer
+	if err := GobCodec.NewEncoder(&payload).Encode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	var header [walHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload.Bytes()))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write(append(header[:], payload.Bytes()...))
+	_ = f.Close()
+
+	again := openWALCache(t, 3, path)
+	if again.Cap() != 3 {
+		t.Errorf("Cap = %d, want %d", again.Cap(), 3)
+	}
+	cmpWALCache(t, again, src)
+}
+
+func TestCache_WALCodecMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	src := openWALCache(t, 5, path)
+	_ = src.Add("a", "1", 0)
+	src.Close()
+
+	if _, err := NewTyped[string, string](5, WithWAL(path), WithCodec(JSONCodec)); err == nil {
+		t.Error("log written with another codec is replayed")
+	}
+}
+
+func TestCache_WALKeepsTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	clock := cachetest.NewClock(time.Now())
+	src := openWALCache(t, 5, path, WithSlidingExpiration(), WithClock(clock))
+	_ = src.Add(k, v, time.Minute)
+	src.Close()
+
+	// The replayed data needs its TTL for the sliding expiration to push its
+	// expiration forward.
+	dst := openWALCache(t, 5, path, WithSlidingExpiration(), WithClock(clock))
+	for i := 0; i < 3; i++ {
+		clock.Advance(40 * time.Second)
+		if _, found := dst.Get(k); !found {
+			t.Fatalf("replayed data expired after %d reads", i)
+		}
+	}
+	dst.Close()
+
+	// The slides are logged with the TTL too.
+	again := openWALCache(t, 5, path, WithSlidingExpiration(), WithClock(clock))
+	cmpWALCache(t, again, dst)
+}